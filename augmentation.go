@@ -38,16 +38,80 @@ type AugmentParams struct {
 // Augment generates new samples based on a SampleSet of
 // complete solves.
 func Augment(s *SampleSet, params *AugmentParams) {
-	s.Samples = append(s.Samples, augmentCrossover(s, params.Crossover)...)
-	s.Samples = append(s.Samples, augmentLastLayer(s, params.LLCases)...)
 	var extra []Sample
+	AugmentEach(s, params, func(sample Sample) error {
+		extra = append(extra, sample)
+		return nil
+	})
+	s.Samples = append(s.Samples, extra...)
+}
+
+// AugmentEach is like Augment, but it passes each new
+// sample to f as soon as it is generated instead of adding
+// it to the set, so the augmented samples never have to be
+// held in memory at once.
+// It stops at the first error from f.
+//
+// Last layer cases are generated for the original and
+// crossover samples, and skips are generated for every
+// other sample.
+func AugmentEach(s *SampleSet, params *AugmentParams, f func(Sample) error) error {
+	withSkips := func(sample Sample) error {
+		if err := f(sample); err != nil {
+			return err
+		}
+		return augmentSkips(sample, params, f)
+	}
+	withLastLayer := func(sample Sample) error {
+		prefix, ok := sampleF2LPrefix(sample)
+		if !ok {
+			return nil
+		}
+		for i := 0; i < params.LLCases; i++ {
+			if err := withSkips(randomizeLastLayer(sample, prefix)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	crossover := newCrossoverGenerator(s)
+	for i := 0; i < params.Crossover && crossover != nil; i++ {
+		sample := crossover.Generate()
+		if err := withSkips(sample); err != nil {
+			return err
+		}
+		if err := withLastLayer(sample); err != nil {
+			return err
+		}
+	}
+	for _, sample := range s.Samples {
+		if err := augmentSkips(sample, params, f); err != nil {
+			return err
+		}
+		if err := withLastLayer(sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func augmentSkips(sample Sample, params *AugmentParams, f func(Sample) error) error {
 	if params.CrossSkips {
-		extra = append(extra, augmentCrossSkips(s)...)
+		if skip, ok := crossSkip(sample); ok {
+			if err := f(skip); err != nil {
+				return err
+			}
+		}
 	}
 	if params.FirstSkips {
-		extra = append(extra, augmentFirstSkips(s)...)
+		if skip, ok := firstSkip(sample); ok {
+			if err := f(skip); err != nil {
+				return err
+			}
+		}
 	}
-	s.Samples = append(s.Samples, extra...)
+	return nil
 }
 
 type crossoverTransition struct {
@@ -55,13 +119,32 @@ type crossoverTransition struct {
 	moves    []string
 }
 
-func augmentCrossover(s *SampleSet, count int) []Sample {
+// A crossoverGenerator performs "genetic" crossover on
+// reconstructions by splicing together the F2L pairs of
+// different solves.
+//
+// Only solves with the same conditioned style (see
+// crossoverStyle) are spliced together, and the result
+// has their style.
+type crossoverGenerator struct {
+	styles      []Style
+	transitions map[Style]map[string][]crossoverTransition
+}
+
+func newCrossoverGenerator(s *SampleSet) *crossoverGenerator {
 	if len(s.Samples) == 0 {
 		return nil
 	}
 
-	transitions := map[string][]crossoverTransition{}
+	res := &crossoverGenerator{transitions: map[Style]map[string][]crossoverTransition{}}
 	for _, sample := range s.Samples {
+		style := crossoverStyle(&s.Inputs, sample.Style())
+		res.styles = append(res.styles, style)
+		transitions, ok := res.transitions[style]
+		if !ok {
+			transitions = map[string][]crossoverTransition{}
+			res.transitions[style] = transitions
+		}
 		cube := *sample.Start
 		var mostSolved int
 		var lastState string
@@ -88,81 +171,86 @@ func augmentCrossover(s *SampleSet, count int) []Sample {
 		}
 		transitions[lastState] = append(transitions[lastState], finalTrans)
 	}
-
-	var res []Sample
-	for i := 0; i < count; i++ {
-		var moves []string
-		var state string
-		for state != "done" {
-			transOptions := transitions[state]
-			t := transOptions[rand.Intn(len(transOptions))]
-			state = t.newState
-			moves = append(moves, t.moves...)
-		}
-		cube := gocube.SolvedCubieCube()
-		for i := len(moves) - 1; i >= 0; i-- {
-			MoveInverse(&cube, moves[i])
-		}
-		res = append(res, Sample{
-			Start: &cube,
-			Moves: strings.Join(moves, " "),
-		})
-	}
 	return res
 }
 
-func augmentLastLayer(orig *SampleSet, numCases int) []Sample {
-	var res []Sample
-	for _, sample := range orig.Samples {
-		prefix, ok := sampleF2LPrefix(sample)
-		if ok {
-			for i := 0; i < numCases; i++ {
-				res = append(res, randomizeLastLayer(sample, prefix))
-			}
-		}
+// Generate creates a random crossover sample.
+// Its style is chosen in proportion to the number of
+// solves with each style.
+func (c *crossoverGenerator) Generate() Sample {
+	style := c.styles[rand.Intn(len(c.styles))]
+	transitions := c.transitions[style]
+	var moves []string
+	var state string
+	for state != "done" {
+		transOptions := transitions[state]
+		t := transOptions[rand.Intn(len(transOptions))]
+		state = t.newState
+		moves = append(moves, t.moves...)
+	}
+	cube := gocube.SolvedCubieCube()
+	for i := len(moves) - 1; i >= 0; i-- {
+		MoveInverse(&cube, moves[i])
+	}
+	return Sample{
+		Start:  &cube,
+		Moves:  strings.Join(moves, " "),
+		Method: style.Method,
+		Solver: style.Solver,
+		Origin: OriginCrossover,
 	}
-	return res
 }
 
-func augmentCrossSkips(orig *SampleSet) []Sample {
-	var res []Sample
-	for _, sample := range orig.Samples {
-		if hasCrossSolved(sample.Start) {
-			continue
-		}
-		cube := *sample.Start
-		moves := strings.Fields(sample.Moves)
-		for i, move := range moves {
-			Move(&cube, move)
-			if hasCrossSolved(&cube) {
-				res = append(res, Sample{
-					Moves: strings.Join(moves[i+1:], " "),
-					Start: &cube,
-				})
-				break
-			}
-		}
+// crossoverStyle returns the parts of a style which a
+// network with the given inputs is conditioned on.
+func crossoverStyle(inputs *InputConfig, style Style) Style {
+	var res Style
+	if len(inputs.Methods) > 0 {
+		res.Method = style.Method
+	}
+	if len(inputs.Solvers) > 0 {
+		res.Solver = style.Solver
 	}
 	return res
 }
 
-func augmentFirstSkips(orig *SampleSet) []Sample {
-	var res []Sample
-	for _, sample := range orig.Samples {
-		cube := *sample.Start
-		if hasCrossSolved(&cube) {
-			continue
-		}
-		Move(&cube, strings.Fields(sample.Moves)[0])
+func crossSkip(sample Sample) (Sample, bool) {
+	if hasCrossSolved(sample.Start) {
+		return Sample{}, false
+	}
+	cube := *sample.Start
+	moves := strings.Fields(sample.Moves)
+	for i, move := range moves {
+		Move(&cube, move)
 		if hasCrossSolved(&cube) {
-			continue
+			return Sample{
+				Moves:  strings.Join(moves[i+1:], " "),
+				Start:  &cube,
+				Method: sample.Method,
+				Solver: sample.Solver,
+				Origin: OriginCrossSkip,
+			}, true
 		}
-		res = append(res, Sample{
-			Moves: strings.Join(strings.Fields(sample.Moves)[1:], " "),
-			Start: &cube,
-		})
 	}
-	return res
+	return Sample{}, false
+}
+
+func firstSkip(sample Sample) (Sample, bool) {
+	cube := *sample.Start
+	if hasCrossSolved(&cube) {
+		return Sample{}, false
+	}
+	Move(&cube, strings.Fields(sample.Moves)[0])
+	if hasCrossSolved(&cube) {
+		return Sample{}, false
+	}
+	return Sample{
+		Moves:  strings.Join(strings.Fields(sample.Moves)[1:], " "),
+		Start:  &cube,
+		Method: sample.Method,
+		Solver: sample.Solver,
+		Origin: OriginFirstSkip,
+	}, true
 }
 
 func randomizeLastLayer(s Sample, f2lSolve string) Sample {
@@ -183,7 +271,8 @@ func randomizeLastLayer(s Sample, f2lSolve string) Sample {
 	for i := len(moves) - 1; i >= 0; i-- {
 		MoveInverse(&cube, moves[i])
 	}
-	return Sample{Start: &cube, Moves: f2lSolve}
+	return Sample{Start: &cube, Moves: f2lSolve, Method: s.Method, Solver: s.Solver,
+		Origin: OriginLastLayer}
 }
 
 func sampleF2LPrefix(s Sample) (string, bool) {
@@ -0,0 +1,131 @@
+package humancube
+
+import (
+	"math"
+	"sort"
+
+	"github.com/unixpickle/gocube"
+)
+
+// A Solution is a sequence of moves produced by a solver.
+type Solution struct {
+	// Moves are the network's moves, made after StartCube.
+	Moves []string
+
+	// LogProb is the log probability the network assigned
+	// to Moves.
+	LogProb float64
+
+	// Solved is false if no solution was found, in which
+	// case Moves is the most promising partial solve.
+	Solved bool
+}
+
+// BeamParams configures BeamSearch.
+type BeamParams struct {
+	// BeamSize is the number of partial solves kept at
+	// each step.
+	BeamSize int
+
+	// MaxMoves is the maximum solution length.
+	MaxMoves int
+}
+
+type beamCandidate struct {
+	parent  *PolicyState
+	eval    *Evaluation
+	moveIdx int
+	logProb float64
+}
+
+// BeamSearch searches for the most likely solution by
+// keeping the BeamSize most likely partial solves at each
+// step.
+// Only the first partial solve found to each cube state is
+// kept, which is the likeliest one of its length.
+// Later partial solves to the same state are dropped, even
+// if a longer one has a higher probability.
+//
+// The cube should already have been passed through
+// StartCube.
+func (n *Network) BeamSearch(c *gocube.CubieCube, p *BeamParams) *Solution {
+	moveNames := n.MoveNames()
+	start := n.StartState(c)
+	if c.Solved() {
+		return &Solution{Solved: true}
+	}
+
+	beam := []*PolicyState{start}
+	partial := start
+	visited := map[string]bool{cubeKey(c): true}
+	var best *PolicyState
+
+	for step := 0; step < p.MaxMoves && len(beam) > 0; step++ {
+		// Probabilities only decrease, so nothing left in the
+		// beam can beat a solution that is already likelier.
+		if best != nil && best.LogProb >= beam[0].LogProb {
+			break
+		}
+
+		var candidates []beamCandidate
+		for i, eval := range n.Evaluate(beam) {
+			for moveIdx, logProb := range eval.Policy {
+				if math.IsInf(logProb, -1) {
+					continue
+				}
+				candidates = append(candidates, beamCandidate{
+					parent:  beam[i],
+					eval:    eval,
+					moveIdx: moveIdx,
+					logProb: beam[i].LogProb + logProb,
+				})
+			}
+		}
+		sort.Sort(beamCandidates(candidates))
+
+		var nextBeam []*PolicyState
+		for _, candidate := range candidates {
+			if len(nextBeam) == p.BeamSize {
+				break
+			}
+			if best != nil && candidate.logProb <= best.LogProb {
+				break
+			}
+			child := candidate.parent.Child(candidate.eval, moveNames[candidate.moveIdx],
+				candidate.moveIdx)
+			key := cubeKey(&child.Cube)
+			if visited[key] {
+				continue
+			}
+			visited[key] = true
+			if child.Cube.Solved() {
+				best = child
+			} else {
+				nextBeam = append(nextBeam, child)
+			}
+		}
+		beam = nextBeam
+		if len(beam) > 0 {
+			partial = beam[0]
+		}
+	}
+
+	if best != nil {
+		return &Solution{Moves: best.Moves, LogProb: best.LogProb, Solved: true}
+	}
+	return &Solution{Moves: partial.Moves, LogProb: partial.LogProb}
+}
+
+type beamCandidates []beamCandidate
+
+func (b beamCandidates) Len() int {
+	return len(b)
+}
+
+func (b beamCandidates) Less(i, j int) bool {
+	return b[i].logProb > b[j].logProb
+}
+
+func (b beamCandidates) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
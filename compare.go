@@ -0,0 +1,110 @@
+package humancube
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+)
+
+// A Prediction is a move and the probability a network
+// assigned to it.
+type Prediction struct {
+	Move string
+	Prob float64
+}
+
+// A ComparisonStep compares the network to a human at one
+// move of a solve.
+type ComparisonStep struct {
+	// Move is the human's move.
+	Move  string
+	Phase Phase
+
+	// Prob is the probability the network assigned to the
+	// human's move, and Rank is the number of moves the
+	// network thought were likelier.
+	Prob float64
+	Rank int
+
+	// Top contains the network's likeliest moves.
+	Top []Prediction
+}
+
+// A Comparison steps through a human solve, showing what
+// a network would have done at every move.
+type Comparison struct {
+	Solve ReconstructedSolve
+	Steps []ComparisonStep
+
+	// Divergence is the index of the first step where the
+	// human's move was not the network's likeliest move, or
+	// -1 if there was no such step.
+	Divergence int
+}
+
+// Compare feeds a human solve to the network and records
+// its topK predictions at every move.
+// The network is conditioned on the solve's method and
+// solver.
+//
+// For canonical networks, the human's moves are converted
+// with CanonicalSample.
+func (n *Network) Compare(solve ReconstructedSolve, topK int) (*Comparison, error) {
+	cube, err := CubeForMoves(solve.Scramble)
+	if err != nil {
+		return nil, errors.New("parse scramble: " + err.Error())
+	}
+	sample := Sample{
+		Start:  cube,
+		Moves:  solve.Reconstruction,
+		Method: solve.Method,
+		Solver: solve.Solver,
+	}
+	if n.Canonical {
+		sample, err = CanonicalSample(sample, n.Reference)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res := &Comparison{Solve: solve, Divergence: -1}
+	moveNames := n.MoveNames()
+	state := n.StartState(sample.Start)
+	state.style = sample.Style()
+	for _, move := range strings.Fields(sample.Moves) {
+		moveIdx, ok := n.MoveMap[move]
+		if !ok {
+			return nil, errors.New("network cannot make move: " + move)
+		}
+		eval := n.Evaluate([]*PolicyState{state})[0]
+
+		var options []sampleOption
+		for i, logProb := range eval.Policy {
+			options = append(options, sampleOption{idx: i, prob: logProb})
+		}
+		sort.Sort(sampleOptions(options))
+		step := ComparisonStep{
+			Move:  move,
+			Phase: DetectPhase(&state.Cube),
+			Prob:  math.Exp(eval.Policy[moveIdx]),
+			Rank:  policyRank(eval.Policy, moveIdx),
+		}
+		for i, option := range options {
+			if i < topK {
+				step.Top = append(step.Top, Prediction{
+					Move: moveNames[option.idx],
+					Prob: math.Exp(option.prob),
+				})
+			}
+		}
+		if step.Rank > 0 && res.Divergence < 0 {
+			res.Divergence = len(res.Steps)
+		}
+		res.Steps = append(res.Steps, step)
+
+		state = state.Child(eval, move, moveIdx)
+	}
+
+	return res, nil
+}
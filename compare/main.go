@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/humancube"
+)
+
+const htmlTemplate = `<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Solve {{.Solve.ID}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: left; }
+tr.miss { background: #fff4e0; }
+tr.divergence { background: #ffd6d6; }
+</style>
+</head>
+<body>
+<h1>Solve {{.Solve.ID}}</h1>
+<p>Solver: {{.Solve.Solver}}<br>Method: {{.Solve.Method}}<br>Scramble: {{.Solve.Scramble}}</p>
+<table>
+<tr><th>#</th><th>Phase</th><th>Human</th><th>P(human)</th><th>Rank</th><th>Network</th></tr>
+{{range $i, $step := .Steps}}<tr class="{{rowClass $i $step}}">
+<td>{{$i}}</td><td>{{$step.Phase}}</td><td>{{$step.Move}}</td>
+<td>{{percent $step.Prob}}</td><td>{{inc $step.Rank}}</td>
+<td>{{range $step.Top}}{{.Move}} ({{percent .Prob}}) {{end}}</td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+func main() {
+	var topK int
+	var htmlFile string
+	flag.IntVar(&topK, "top", 5, "number of network predictions to show per move")
+	flag.StringVar(&htmlFile, "html", "", "also write an HTML report to this file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] network_file data_file solve_id")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := Compare(flag.Arg(0), flag.Arg(1), flag.Arg(2), topK, htmlFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func Compare(netFile, dataFile, idStr string, topK int, htmlFile string) error {
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return errors.New("bad solve ID: " + idStr)
+	}
+	net, err := humancube.ReadNetwork(netFile)
+	if err != nil {
+		return err
+	}
+	solve, err := findSolve(dataFile, id)
+	if err != nil {
+		return err
+	}
+	comparison, err := net.Compare(*solve, topK)
+	if err != nil {
+		return err
+	}
+
+	printComparison(comparison)
+	if htmlFile != "" {
+		return writeHTML(htmlFile, comparison)
+	}
+	return nil
+}
+
+func findSolve(dataFile string, id int) (*humancube.ReconstructedSolve, error) {
+	contents, err := ioutil.ReadFile(dataFile)
+	if err != nil {
+		return nil, err
+	}
+	var solves []humancube.ReconstructedSolve
+	if err := json.Unmarshal(contents, &solves); err != nil {
+		return nil, err
+	}
+	for _, solve := range solves {
+		if solve.ID == id {
+			return &solve, nil
+		}
+	}
+	return nil, fmt.Errorf("no solve with ID %d", id)
+}
+
+func printComparison(c *humancube.Comparison) {
+	fmt.Println("Solve", c.Solve.ID, "by", c.Solve.Solver, "("+c.Solve.Method+")")
+	fmt.Println("Scramble:", c.Solve.Scramble)
+	for i, step := range c.Steps {
+		marker := " "
+		if i == c.Divergence {
+			marker = ">"
+		} else if step.Rank > 0 {
+			marker = "*"
+		}
+		var top []string
+		for _, prediction := range step.Top {
+			top = append(top, fmt.Sprintf("%s %.1f%%", prediction.Move, prediction.Prob*100))
+		}
+		fmt.Printf("%s %3d %-6s %-4s %5.1f%% rank %-2d | %s\n", marker, i, step.Phase, step.Move,
+			step.Prob*100, step.Rank+1, strings.Join(top, ", "))
+	}
+	if c.Divergence < 0 {
+		fmt.Println("The network's likeliest move matched every human move.")
+	} else {
+		fmt.Println("First divergence at move", c.Divergence)
+	}
+}
+
+func writeHTML(path string, c *humancube.Comparison) error {
+	tmpl, err := template.New("comparison").Funcs(template.FuncMap{
+		"percent": func(p float64) string {
+			return fmt.Sprintf("%.1f%%", p*100)
+		},
+		"inc": func(x int) int {
+			return x + 1
+		},
+		"rowClass": func(i int, step humancube.ComparisonStep) string {
+			if i == c.Divergence {
+				return "divergence"
+			} else if step.Rank > 0 {
+				return "miss"
+			}
+			return ""
+		},
+	}).Parse(htmlTemplate)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return tmpl.Execute(f, c)
+}
@@ -8,27 +8,29 @@ import (
 	"github.com/unixpickle/num-analysis/linalg"
 )
 
+// hybridMoves expresses wide turns and slice moves as
+// face turns and rotations.
+var hybridMoves = map[string]string{
+	"r": "L x",
+	"l": "R x'",
+	"u": "D y",
+	"d": "U y'",
+	"f": "B z",
+	"b": "F z'",
+	"M": "R L' x'",
+	"E": "U D' y'",
+	"S": "F' B z",
+}
+
 // Move applies a WCA-notation move to the cube.
 func Move(c *gocube.CubieCube, s string) error {
 	s = strings.Replace(s, "2'", "2", 1)
 
-	hybrids := map[string]string{
-		"r": "L x",
-		"l": "R x'",
-		"u": "D y",
-		"d": "U y'",
-		"f": "B z",
-		"b": "F z'",
-		"M": "R L' x'",
-		"E": "U D' y'",
-		"S": "F' B z",
-	}
-
 	if len(s) == 0 {
 		return errors.New("empty move is invalid")
 	}
 
-	if hybrid, ok := hybrids[s[:1]]; ok {
+	if hybrid, ok := hybridMoves[s[:1]]; ok {
 		if len(s) == 1 {
 			moves := strings.Fields(hybrid)
 			for _, move := range moves {
@@ -69,12 +71,17 @@ func Move(c *gocube.CubieCube, s string) error {
 
 // MoveInverse performs the inverse of Move.
 func MoveInverse(c *gocube.CubieCube, s string) error {
+	return Move(c, InverseMove(s))
+}
+
+// InverseMove returns the name of the inverse of a move.
+func InverseMove(s string) string {
 	if strings.HasSuffix(s, "'") {
-		return Move(c, s[:len(s)-1])
+		return s[:len(s)-1]
 	} else if strings.HasSuffix(s, "2") {
-		return Move(c, s)
+		return s
 	} else {
-		return Move(c, s+"'")
+		return s + "'"
 	}
 }
 
@@ -92,24 +99,7 @@ func CubeForMoves(moveStr string) (*gocube.CubieCube, error) {
 
 // CubeVector returns a vectorized representation of
 // the stickers of a cube.
+// It is equivalent to StickerEncoder.Encode.
 func CubeVector(c *gocube.CubieCube) linalg.Vector {
-	stickerCube := c.StickerCube()
-	res := make(linalg.Vector, 8*6*6)
-
-	var stickerIdx int
-	for i, sticker := range stickerCube[:] {
-		if i%9 == 4 {
-			continue
-		}
-		for j := 0; j < 6; j++ {
-			if j == sticker-1 {
-				res[j+stickerIdx] = 1.0
-			} else {
-				res[j+stickerIdx] = -0.2
-			}
-		}
-		stickerIdx += 6
-	}
-
-	return res
+	return StickerEncoder{}.Encode(c)
 }
@@ -0,0 +1,63 @@
+package humancube
+
+import "strings"
+
+// CurriculumStages lists the stages of a curriculum from
+// easiest to hardest.
+// Samples in each stage start with at least MinProgress.
+var CurriculumStages = []CurriculumStage{
+	{Name: "last layer", MinProgress: 5},
+	{Name: "last slot", MinProgress: 4},
+	{Name: "F2L", MinProgress: 1},
+	{Name: "full", MinProgress: 0},
+}
+
+// A CurriculumStage is a set of samples which start at a
+// certain point in the solve.
+type CurriculumStage struct {
+	Name        string
+	MinProgress int
+}
+
+// SampleFrom truncates a sample so that it starts the
+// first time the cube reaches minProgress.
+// It returns false if the sample never reaches
+// minProgress before the cube is solved.
+func SampleFrom(s Sample, minProgress int) (Sample, bool) {
+	cube := *s.Start
+	moves := strings.Fields(s.Moves)
+	for i := 0; i < len(moves); i++ {
+		if Progress(&cube) >= minProgress {
+			if i == 0 {
+				return s, true
+			}
+			res := s
+			res.Start = &cube
+			res.Moves = strings.Join(moves[i:], " ")
+			res.hash = ""
+			return res, true
+		}
+		Move(&cube, moves[i])
+	}
+	return Sample{}, false
+}
+
+// CurriculumSubset creates a copy of the sample set
+// containing the samples of a curriculum stage.
+// Every sample is truncated with SampleFrom, and samples
+// which never reach the stage are dropped.
+func (s *SampleSet) CurriculumSubset(stage int) *SampleSet {
+	res := &SampleSet{
+		MoveMap: s.MoveMap,
+		Encoder: s.Encoder,
+		Inputs:  s.Inputs,
+		Outputs: s.Outputs,
+	}
+	minProgress := CurriculumStages[stage].MinProgress
+	for _, sample := range s.Samples {
+		if truncated, ok := SampleFrom(sample, minProgress); ok {
+			res.Samples = append(res.Samples, truncated)
+		}
+	}
+	return res
+}
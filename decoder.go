@@ -0,0 +1,37 @@
+package humancube
+
+import "github.com/unixpickle/gocube"
+
+// A Decoder chooses how a network's policy is turned into
+// a solution.
+//
+// If MCTS is set, MCTS is used.
+// Otherwise, if Beam is set, BeamSearch is used.
+// Otherwise, moves are sampled with Sampler, or with
+// DefaultSampler if Sampler is nil.
+type Decoder struct {
+	Sampler *Sampler
+	Beam    *BeamParams
+	MCTS    *MCTSParams
+
+	// MaxMoves limits the length of sampled solutions.
+	MaxMoves int
+}
+
+// Solve decodes a solution for a cube.
+//
+// The cube should already have been passed through
+// StartCube.
+func (d *Decoder) Solve(n *Network, c *gocube.CubieCube) *Solution {
+	if d.MCTS != nil {
+		return n.MCTS(c, d.MCTS)
+	} else if d.Beam != nil {
+		return n.BeamSearch(c, d.Beam)
+	}
+	sampler := d.Sampler
+	if sampler == nil {
+		defaultSampler := DefaultSampler
+		sampler = &defaultSampler
+	}
+	return n.SampleSolve(c, sampler, d.MaxMoves)
+}
@@ -0,0 +1,129 @@
+package humancube
+
+import (
+	"errors"
+	"flag"
+	"time"
+)
+
+// DecoderOptions are the settings, shared by every command
+// which solves cubes with a network, that choose the style
+// and the decoding algorithm.
+type DecoderOptions struct {
+	Style   Style
+	Sampler Sampler
+	Mask    MoveMask
+	Beam    int
+
+	MCTS            int
+	MCTSTime        time.Duration
+	MCTSExploration float64
+	MCTSValue       string
+}
+
+// AddStyleFlags registers the flags which set a Style.
+func AddStyleFlags(f *flag.FlagSet, s *Style) {
+	f.StringVar(&s.Method, "method", "", "method for a conditioned network to use")
+	f.StringVar(&s.Solver, "solver", "", "solver for a conditioned network to imitate")
+}
+
+// AddFlags registers flags for the options.
+// The beam flag defaults to beam.
+func (d *DecoderOptions) AddFlags(f *flag.FlagSet, beam int) {
+	AddStyleFlags(f, &d.Style)
+	f.Float64Var(&d.Sampler.Temperature, "temp", 1, "sampling temperature (0 for argmax)")
+	f.IntVar(&d.Sampler.TopK, "topk", 0, "only sample from the k likeliest moves")
+	f.Float64Var(&d.Sampler.TopP, "topp", 0, "only sample from the likeliest moves "+
+		"with this total probability")
+	f.BoolVar(&d.Mask.NoRepeatFace, "norepeat", false,
+		"never turn the same face twice in a row")
+	f.IntVar(&d.Mask.MaxRotations, "maxrotations", 0,
+		"maximum rotations in a row (0 for no limit)")
+	f.BoolVar(&d.Mask.NoPhaseStartRotation, "nophaserotation", false,
+		"never rotate at the start of the solve or of a phase")
+	f.IntVar(&d.Beam, "beam", beam, "use beam search with this beam size (0 to sample)")
+	f.IntVar(&d.MCTS, "mcts", 0, "use MCTS with this many simulations per move")
+	f.DurationVar(&d.MCTSTime, "mctstime", 0, "time limit for MCTS (0 for none)")
+	f.Float64Var(&d.MCTSExploration, "mctsc", 1.5, "MCTS exploration constant")
+	f.StringVar(&d.MCTSValue, "mctsvalue", ProgressValue, "MCTS leaf value (progress, network)")
+}
+
+// Validate checks that the options are usable.
+func (d *DecoderOptions) Validate() error {
+	if err := d.Sampler.Validate(); err != nil {
+		return err
+	}
+	if mcts := d.Decoder(1).MCTS; mcts != nil {
+		return mcts.Validate()
+	}
+	return nil
+}
+
+// Apply sets the Style and Mask of a network.
+func (d *DecoderOptions) Apply(n *Network) {
+	n.Style = d.Style
+	if d.Mask != (MoveMask{}) {
+		mask := d.Mask
+		n.Mask = &mask
+	}
+}
+
+// Decoder creates the Decoder selected by the options.
+// Solutions are limited to maxMoves moves.
+func (d *DecoderOptions) Decoder(maxMoves int) *Decoder {
+	sampler := d.Sampler
+	res := &Decoder{Sampler: &sampler, MaxMoves: maxMoves}
+	if d.MCTS > 0 {
+		res.MCTS = &MCTSParams{
+			Simulations: d.MCTS,
+			TimeLimit:   d.MCTSTime,
+			Exploration: d.MCTSExploration,
+			MaxMoves:    maxMoves,
+			Value:       d.MCTSValue,
+		}
+	} else if d.Beam > 0 {
+		res.Beam = &BeamParams{BeamSize: d.Beam, MaxMoves: maxMoves}
+	}
+	return res
+}
+
+// HybridOptions are the command-line settings for hybrid
+// solves.
+type HybridOptions struct {
+	// Stage is the name of the HandOff stage (see
+	// HybridHandOff), or "" for no hybrid solves.
+	Stage string
+
+	SolverTime  time.Duration
+	SolverMoves int
+}
+
+// AddFlags registers flags for the options.
+func (h *HybridOptions) AddFlags(f *flag.FlagSet) {
+	f.StringVar(&h.Stage, "hybrid", "", "finish with a classical solver after this "+
+		"stage (cross, f2l, oll, best)")
+	f.DurationVar(&h.SolverTime, "solvertime", time.Second,
+		"time for the classical solver to improve its solution")
+	f.IntVar(&h.SolverMoves, "solvermoves", 30,
+		"maximum length of the classical solver's solution")
+}
+
+// Params creates the HybridParams selected by the options,
+// or returns nil if hybrid solves are disabled.
+func (h *HybridOptions) Params() (*HybridParams, error) {
+	if h.Stage == "" {
+		return nil, nil
+	}
+	handOff, err := HybridHandOff(h.Stage)
+	if err != nil {
+		return nil, err
+	}
+	if h.SolverMoves <= 0 {
+		return nil, errors.New("solver moves must be positive")
+	}
+	return &HybridParams{
+		HandOff:        handOff,
+		SolverTime:     h.SolverTime,
+		MaxSolverMoves: h.SolverMoves,
+	}, nil
+}
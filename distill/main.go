@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/humancube"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/sgd"
+	"github.com/unixpickle/weakai/rnn"
+	"github.com/unixpickle/weakai/rnn/seqtoseq"
+)
+
+const (
+	MaxSolveLength = 200
+	ScrambleLength = 25
+)
+
+type Options struct {
+	Rounds      int
+	Scrambles   int
+	Batches     int
+	BatchSize   int
+	StepSize    float64
+	ValueWeight float64
+	Workers     int
+	Seed        int64
+
+	Augment      humancube.AugmentParams
+	SearchWeight float64
+
+	Decoder humancube.DecoderOptions
+}
+
+func main() {
+	var opts Options
+	flag.IntVar(&opts.Rounds, "rounds", 10, "rounds of search and training")
+	flag.IntVar(&opts.Scrambles, "n", 100, "random scrambles to solve per round")
+	flag.IntVar(&opts.Batches, "batches", 200, "training batches per round")
+	flag.IntVar(&opts.BatchSize, "batch", 32, "samples per training batch")
+	flag.Float64Var(&opts.StepSize, "step", 1e-4, "Adam step size")
+	flag.Float64Var(&opts.ValueWeight, "valueweight", 1, "cost weight of the value head")
+	flag.IntVar(&opts.Workers, "workers", runtime.NumCPU(), "number of parallel solves")
+	flag.Int64Var(&opts.Seed, "seed", time.Now().UnixNano(), "seed for random scrambles")
+	flag.IntVar(&opts.Augment.Crossover, "crossover", 0,
+		"number of crossover samples to generate per round")
+	flag.IntVar(&opts.Augment.LLCases, "llcases", 0, "last layer cases to generate per solve")
+	flag.BoolVar(&opts.Augment.CrossSkips, "crossskips", false,
+		"generate samples starting with a solved cross")
+	flag.BoolVar(&opts.Augment.FirstSkips, "firstskips", false,
+		"generate samples with the first move made")
+	flag.Float64Var(&opts.SearchWeight, "searchweight", 1,
+		"cost weight of the samples solved by search")
+	opts.Decoder.AddFlags(flag.CommandLine, 16)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
+			"[flags] network_file data_file out_network out_data")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	flag.Parse()
+
+	if flag.NArg() != 4 {
+		flag.Usage()
+	}
+	err := Distill(flag.Arg(0), flag.Arg(1), flag.Arg(2), flag.Arg(3), &opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Distill runs expert iteration: every round, it solves
+// random scrambles with search, adds the solutions to the
+// data as synthetic solves, and trains the network on the
+// human and synthetic solves.
+func Distill(netFile, dataFile, outNet, outData string, opts *Options) error {
+	if opts.Scrambles <= 0 || opts.BatchSize <= 0 || opts.Workers <= 0 {
+		return errors.New("scrambles, batch size, and workers must be positive")
+	}
+	if opts.SearchWeight < 0 {
+		return errors.New("search weight must not be negative")
+	}
+	if err := opts.Decoder.Validate(); err != nil {
+		return err
+	}
+	net, err := humancube.ReadNetwork(netFile)
+	if err != nil {
+		return errors.New("load network: " + err.Error())
+	}
+	opts.Decoder.Apply(net)
+	solves, err := readSolves(dataFile)
+	if err != nil {
+		return errors.New("load data: " + err.Error())
+	}
+
+	decoder := opts.Decoder.Decoder(MaxSolveLength)
+
+	gradienter := &sgd.Adam{
+		Gradienter: &seqtoseq.Gradienter{
+			SeqFunc:  &rnn.BlockSeqFunc{B: net.Block},
+			Learner:  net.Block,
+			CostFunc: net.CostFunc(opts.ValueWeight),
+			MaxLanes: opts.BatchSize,
+		},
+		DecayRate1: 0.9,
+		DecayRate2: 0.999,
+		Damping:    1e-8,
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	rand.Seed(opts.Seed)
+	log.Println("Press ctrl+c to stop after the current round.")
+	for round := 0; round < opts.Rounds; round++ {
+		net.Dropout(false)
+		synthetic := searchSolves(net, decoder, opts)
+		log.Printf("round %d: search solved %d/%d scrambles", round, len(synthetic),
+			opts.Scrambles)
+		solves = append(solves, synthetic...)
+		if err := writeSolves(outData, solves); err != nil {
+			return errors.New("save data: " + err.Error())
+		}
+
+		training, validation, err := trainingSets(net, solves, opts)
+		if err != nil {
+			return err
+		}
+		if training.Len() < opts.BatchSize {
+			return errors.New("not enough samples")
+		}
+		net.Dropout(true)
+		var batches int
+		sgd.SGDMini(gradienter, training, opts.StepSize, opts.BatchSize,
+			func(sgd.SampleSet) bool {
+				batches++
+				return batches <= opts.Batches
+			})
+		net.Dropout(false)
+
+		metrics := net.Imitate(validation, opts.BatchSize)
+		log.Printf("round %d: trained on %d samples, validation top1=%f",
+			round, training.Len(), metrics.Total.Top1Accuracy())
+
+		log.Println("Saving...")
+		if err := serializer.SaveAny(outNet, net); err != nil {
+			return errors.New("save network: " + err.Error())
+		}
+
+		select {
+		case <-interrupt:
+			log.Println("Stopping early...")
+			return nil
+		default:
+		}
+	}
+	return nil
+}
+
+// searchSolves solves random scrambles with search and
+// returns the synthetic solves which succeeded.
+func searchSolves(net *humancube.Network, decoder *humancube.Decoder,
+	opts *Options) []humancube.ReconstructedSolve {
+	scrambles := make([]string, opts.Scrambles)
+	indices := make(chan int, opts.Scrambles)
+	for i := range scrambles {
+		scrambles[i] = humancube.RandomScramble(ScrambleLength)
+		indices <- i
+	}
+	close(indices)
+
+	solves := make([]humancube.ReconstructedSolve, len(scrambles))
+	solved := make([]bool, len(scrambles))
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indices {
+				solves[idx], solved[idx] = net.SearchSolve(scrambles[idx], decoder)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Keep the solves in scramble order, so that the output
+	// does not depend on which worker finished first.
+	var res []humancube.ReconstructedSolve
+	for i, solve := range solves {
+		if solved[i] {
+			res = append(res, solve)
+		}
+	}
+	return res
+}
+
+// trainingSets converts the solves into the network's
+// augmented and weighted training samples, including every
+// synthetic sample, and its held-out human samples.
+func trainingSets(net *humancube.Network, solves []humancube.ReconstructedSolve,
+	opts *Options) (training, validation *humancube.SampleSet, err error) {
+	samples := humancube.NewSampleSet(solves)
+	if net.Canonical {
+		if err := samples.Canonicalize(net.Reference); err != nil {
+			return nil, nil, errors.New("canonicalize samples: " + err.Error())
+		}
+	}
+	if err := samples.UseMoveMap(net.MoveMap); err != nil {
+		return nil, nil, errors.New("convert samples: " + err.Error())
+	}
+	samples.Encoder = net.Encoder
+	samples.Inputs = net.Config.InputConfig
+	samples.Outputs = net.Config.OutputConfig
+
+	validation, training = net.SplitSamples(samples)
+	humancube.Augment(training, &opts.Augment)
+	training.ApplyWeights(&humancube.SampleWeights{
+		Origins: map[string]float64{humancube.OriginSearch: opts.SearchWeight},
+	})
+	return training, validation, nil
+}
+
+func readSolves(path string) ([]humancube.ReconstructedSolve, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var res []humancube.ReconstructedSolve
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func writeSolves(path string, solves []humancube.ReconstructedSolve) error {
+	data, err := json.Marshal(solves)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
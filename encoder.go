@@ -0,0 +1,214 @@
+package humancube
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// DefaultEncoder is the name of the Encoder used by
+// networks which do not specify one.
+const DefaultEncoder = "stickers"
+
+// An Encoder converts cube states into network inputs.
+type Encoder interface {
+	// Name returns the name which identifies the Encoder
+	// on the command-line and in saved networks.
+	Name() string
+
+	// Size returns the length of encoded vectors.
+	Size() int
+
+	// Encode generates the vector for a cube.
+	Encode(c *gocube.CubieCube) linalg.Vector
+}
+
+var encoders = []Encoder{
+	StickerEncoder{},
+	CubieEncoder{},
+	CrossRelativeEncoder{},
+	CompactEncoder{},
+}
+
+// EncoderNames returns the names of every Encoder.
+func EncoderNames() []string {
+	var res []string
+	for _, e := range encoders {
+		res = append(res, e.Name())
+	}
+	sort.Strings(res)
+	return res
+}
+
+// EncoderForName finds an Encoder by its name.
+func EncoderForName(name string) (Encoder, error) {
+	for _, e := range encoders {
+		if e.Name() == name {
+			return e, nil
+		}
+	}
+	return nil, errors.New("unknown encoder: " + name)
+}
+
+func encoderOrDefault(e Encoder) Encoder {
+	if e == nil {
+		return StickerEncoder{}
+	}
+	return e
+}
+
+// StickerEncoder encodes every non-center sticker as a
+// one-hot vector of its color.
+type StickerEncoder struct{}
+
+// Name returns "stickers".
+func (_ StickerEncoder) Name() string {
+	return "stickers"
+}
+
+// Size returns the size of encoded vectors.
+func (_ StickerEncoder) Size() int {
+	return 8 * 6 * 6
+}
+
+// Encode encodes the cube.
+func (_ StickerEncoder) Encode(c *gocube.CubieCube) linalg.Vector {
+	stickerCube := c.StickerCube()
+	res := make(linalg.Vector, 8*6*6)
+
+	var stickerIdx int
+	for i, sticker := range stickerCube[:] {
+		if i%9 == 4 {
+			continue
+		}
+		for j := 0; j < 6; j++ {
+			if j == sticker-1 {
+				res[j+stickerIdx] = 1.0
+			} else {
+				res[j+stickerIdx] = -0.2
+			}
+		}
+		stickerIdx += 6
+	}
+
+	return res
+}
+
+// CubieEncoder encodes every corner and edge slot as a
+// one-hot vector of the piece and orientation in it.
+type CubieEncoder struct{}
+
+// Name returns "cubies".
+func (_ CubieEncoder) Name() string {
+	return "cubies"
+}
+
+// Size returns the size of encoded vectors.
+func (_ CubieEncoder) Size() int {
+	return 20 * 24
+}
+
+// Encode encodes the cube.
+func (_ CubieEncoder) Encode(c *gocube.CubieCube) linalg.Vector {
+	res := make(linalg.Vector, 20*24)
+	for i, corner := range c.Corners {
+		res[i*24+corner.Piece*3+corner.Orientation] = 1
+	}
+	for i, edge := range c.Edges {
+		idx := (i+8)*24 + edge.Piece*2
+		if edge.Flip {
+			idx++
+		}
+		res[idx] = 1
+	}
+	return res
+}
+
+// CrossRelativeEncoder is like StickerEncoder, but it
+// describes colors relative to the cross color (the color
+// of the bottom face) rather than absolutely.
+//
+// Bottom and top colors are encoded as such.
+// Side colors are encoded by how far around the bottom
+// face they are from the side face they are on, so that
+// every F2L slot looks the same from its own side.
+// Stickers on the top and bottom faces measure side colors
+// relative to the front face.
+type CrossRelativeEncoder struct{}
+
+// Name returns "cross".
+func (_ CrossRelativeEncoder) Name() string {
+	return "cross"
+}
+
+// Size returns the size of encoded vectors.
+func (_ CrossRelativeEncoder) Size() int {
+	return 8 * 6 * 6
+}
+
+// Encode encodes the cube.
+func (_ CrossRelativeEncoder) Encode(c *gocube.CubieCube) linalg.Vector {
+	// Faces and colors are numbered top, bottom, front,
+	// back, right, left.
+	sideIndices := map[int]int{3: 0, 5: 1, 4: 2, 6: 3}
+
+	stickerCube := c.StickerCube()
+	res := make(linalg.Vector, 8*6*6)
+
+	var stickerIdx int
+	for i, sticker := range stickerCube[:] {
+		if i%9 == 4 {
+			continue
+		}
+		var class int
+		switch sticker {
+		case 2:
+			class = 0
+		case 1:
+			class = 1
+		default:
+			face := i/9 + 1
+			ref, ok := sideIndices[face]
+			if !ok {
+				ref = sideIndices[3]
+			}
+			class = 2 + (sideIndices[sticker]-ref+4)%4
+		}
+		for j := 0; j < 6; j++ {
+			if j == class {
+				res[j+stickerIdx] = 1.0
+			} else {
+				res[j+stickerIdx] = -0.2
+			}
+		}
+		stickerIdx += 6
+	}
+
+	return res
+}
+
+// CompactEncoder encodes every sticker (including the
+// centers) as a single number.
+type CompactEncoder struct{}
+
+// Name returns "compact".
+func (_ CompactEncoder) Name() string {
+	return "compact"
+}
+
+// Size returns the size of encoded vectors.
+func (_ CompactEncoder) Size() int {
+	return 6 * 9
+}
+
+// Encode encodes the cube.
+func (_ CompactEncoder) Encode(c *gocube.CubieCube) linalg.Vector {
+	stickerCube := c.StickerCube()
+	res := make(linalg.Vector, 6*9)
+	for i, sticker := range stickerCube[:] {
+		res[i] = float64(sticker - 1)
+	}
+	return res
+}
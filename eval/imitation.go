@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/unixpickle/humancube"
+)
+
+// Accuracy is the JSON form of a humancube.MoveAccuracy.
+type Accuracy struct {
+	Moves      int
+	Top1       float64
+	Top5       float64
+	Perplexity float64
+}
+
+// ImitationResults summarizes teacher-forced accuracy.
+type ImitationResults struct {
+	Samples   int
+	Skipped   int
+	Total     Accuracy
+	Phases    map[string]Accuracy
+	MoveTypes map[string]Accuracy
+}
+
+func Imitation(net *humancube.Network, opts *Options) error {
+	samples, err := heldOutSamples(net, opts)
+	if err != nil {
+		return err
+	}
+	metrics := net.Imitate(samples, opts.BatchSize)
+	res := &ImitationResults{
+		Samples:   len(samples.Samples),
+		Skipped:   metrics.Skipped,
+		Total:     accuracyResult(&metrics.Total),
+		Phases:    accuracyResults(metrics.Phases),
+		MoveTypes: accuracyResults(metrics.MoveTypes),
+	}
+
+	fmt.Printf("Evaluated %d held-out samples (%d cut short)\n", res.Samples, res.Skipped)
+	printAccuracy("all moves", res.Total)
+	fmt.Println("By phase:")
+	for phase := humancube.PhaseCross; phase < humancube.NumPhases; phase++ {
+		if accuracy, ok := res.Phases[phase.String()]; ok {
+			printAccuracy(phase.String(), accuracy)
+		}
+	}
+	fmt.Println("By move type:")
+	var moveTypes []string
+	for moveType := range res.MoveTypes {
+		moveTypes = append(moveTypes, moveType)
+	}
+	sort.Strings(moveTypes)
+	for _, moveType := range moveTypes {
+		printAccuracy(moveType, res.MoveTypes[moveType])
+	}
+
+	return writeJSON(opts.JSONFile, res)
+}
+
+func accuracyResult(a *humancube.MoveAccuracy) Accuracy {
+	return Accuracy{
+		Moves:      a.Moves,
+		Top1:       a.Top1Accuracy(),
+		Top5:       a.Top5Accuracy(),
+		Perplexity: a.Perplexity(),
+	}
+}
+
+func accuracyResults(m map[string]*humancube.MoveAccuracy) map[string]Accuracy {
+	res := map[string]Accuracy{}
+	for name, accuracy := range m {
+		res[name] = accuracyResult(accuracy)
+	}
+	return res
+}
+
+func printAccuracy(name string, a Accuracy) {
+	fmt.Printf("  %-10s moves=%d top1=%.2f%% top5=%.2f%% perplexity=%.3f\n", name+":",
+		a.Moves, a.Top1*100, a.Top5*100, a.Perplexity)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/humancube"
+)
+
+const MaxSolveLength = 200
+
+type Options struct {
+	Count    int
+	Seed     int64
+	DataFile string
+	Workers  int
+	JSONFile string
+
+	Imitation bool
+	BatchSize int
+
+	Decoder humancube.DecoderOptions
+	Hybrid  humancube.HybridOptions
+}
+
+// Results summarizes an evaluation.
+type Results struct {
+	Scrambles int
+	Solved    int
+	SolveRate float64
+
+	// MeanMoves and MedianMoves only count solved cubes.
+	MeanMoves   float64
+	MedianMoves float64
+
+	// MeanNetworkMoves is the mean number of moves kept
+	// from the network in hybrid solves.
+	MeanNetworkMoves float64 `json:",omitempty"`
+
+	// Reached maps the furthest stage each solve reached
+	// (see humancube.ProgressName) to a count.
+	Reached map[string]int
+
+	MeanSeconds  float64
+	TotalSeconds float64
+}
+
+type solveResult struct {
+	solution *humancube.Solution
+	progress int
+	duration time.Duration
+
+	// networkMoves is the number of moves kept from the
+	// network in a hybrid solve.
+	networkMoves int
+}
+
+func main() {
+	var opts Options
+	flag.IntVar(&opts.Count, "n", 100, "number of random scrambles")
+	flag.Int64Var(&opts.Seed, "seed", 1, "seed for random scrambles")
+	flag.StringVar(&opts.DataFile, "data", "", "evaluate on the held-out split of this data "+
+		"file instead of random scrambles")
+	flag.IntVar(&opts.Workers, "workers", runtime.NumCPU(), "number of parallel solves")
+	flag.StringVar(&opts.JSONFile, "json", "", "also write the results to this JSON file")
+	flag.BoolVar(&opts.Imitation, "imitation", false, "measure teacher-forced next-move "+
+		"accuracy on the held-out split of -data instead of solving")
+	flag.IntVar(&opts.BatchSize, "batch", 64, "samples per batch for -imitation")
+	opts.Decoder.AddFlags(flag.CommandLine, 0)
+	opts.Hybrid.AddFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] network_file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := Eval(flag.Arg(0), &opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func Eval(netFile string, opts *Options) error {
+	if opts.Workers <= 0 {
+		return errors.New("workers must be positive")
+	}
+	if err := opts.Decoder.Validate(); err != nil {
+		return err
+	}
+	hybrid, err := opts.Hybrid.Params()
+	if err != nil {
+		return err
+	}
+	net, err := humancube.ReadNetwork(netFile)
+	if err != nil {
+		return err
+	}
+	net.Style = opts.Decoder.Style
+
+	if opts.Imitation {
+		if opts.DataFile == "" {
+			return errors.New("-imitation requires -data")
+		}
+		return Imitation(net, opts)
+	}
+	opts.Decoder.Apply(net)
+
+	cubes, err := startCubes(net, opts)
+	if err != nil {
+		return err
+	}
+
+	decoder := opts.Decoder.Decoder(MaxSolveLength)
+
+	startTime := time.Now()
+	results := solveAll(net, decoder, hybrid, cubes, opts.Workers)
+	summary := summarize(results, time.Since(startTime))
+	printResults(summary, hybrid != nil)
+
+	return writeJSON(opts.JSONFile, summary)
+}
+
+// startCubes generates the cubes to solve, already passed
+// through StartCube.
+func startCubes(net *humancube.Network, opts *Options) ([]*gocube.CubieCube, error) {
+	var res []*gocube.CubieCube
+	if opts.DataFile == "" {
+		rand.Seed(opts.Seed)
+		for i := 0; i < opts.Count; i++ {
+			cube := gocube.RandomCubieCube()
+			if err := net.StartCube(&cube); err != nil {
+				return nil, err
+			}
+			res = append(res, &cube)
+		}
+		return res, nil
+	}
+
+	validation, err := heldOutSamples(net, opts)
+	if err != nil {
+		return nil, err
+	}
+	// ValidationSamples has already passed the starting
+	// cubes through StartCube.
+	for _, sample := range validation.Samples {
+		cube := *sample.Start
+		res = append(res, &cube)
+	}
+	return res, nil
+}
+
+func heldOutSamples(net *humancube.Network, opts *Options) (*humancube.SampleSet, error) {
+	samples, err := humancube.LoadSampleSet(opts.DataFile)
+	if err != nil {
+		return nil, errors.New("load sample set: " + err.Error())
+	}
+	return net.ValidationSamples(samples)
+}
+
+func writeJSON(path string, obj interface{}) error {
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return errors.New("write results: " + err.Error())
+	}
+	return nil
+}
+
+// solveAll solves every cube in parallel.
+// If hybrid is non-nil, each solution is finished by the
+// classical solver, and it only counts as solved if the
+// classical solver succeeds.
+// The furthest stage reached is always that of the
+// network's own moves.
+func solveAll(net *humancube.Network, d *humancube.Decoder, hybrid *humancube.HybridParams,
+	cubes []*gocube.CubieCube, workers int) []solveResult {
+	results := make([]solveResult, len(cubes))
+	indices := make(chan int, len(cubes))
+	for i := range cubes {
+		indices <- i
+	}
+	close(indices)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indices {
+				start := time.Now()
+				solution := d.Solve(net, cubes[idx])
+				result := solveResult{
+					solution: solution,
+					progress: furthestProgress(cubes[idx], solution.Moves),
+				}
+				if hybrid != nil {
+					result.solution = &humancube.Solution{}
+					h, err := humancube.FinishSolution(cubes[idx], solution, hybrid)
+					if err == nil {
+						result.solution.Moves = h.Moves()
+						result.solution.Solved = true
+						result.networkMoves = len(h.NetworkMoves)
+					}
+				}
+				result.duration = time.Since(start)
+				results[idx] = result
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func furthestProgress(start *gocube.CubieCube, moves []string) int {
+	cube := *start
+	res := humancube.Progress(&cube)
+	for _, move := range moves {
+		humancube.Move(&cube, move)
+		if progress := humancube.Progress(&cube); progress > res {
+			res = progress
+		}
+	}
+	return res
+}
+
+func summarize(results []solveResult, total time.Duration) *Results {
+	res := &Results{
+		Scrambles:    len(results),
+		Reached:      map[string]int{},
+		TotalSeconds: total.Seconds(),
+	}
+	var lengths []int
+	var solveTime time.Duration
+	var networkMoves int
+	for _, result := range results {
+		if result.solution.Solved {
+			res.Solved++
+			lengths = append(lengths, len(result.solution.Moves))
+			networkMoves += result.networkMoves
+		}
+		res.Reached[humancube.ProgressName(result.progress)]++
+		solveTime += result.duration
+	}
+	if len(results) > 0 {
+		res.SolveRate = float64(res.Solved) / float64(len(results))
+		res.MeanSeconds = solveTime.Seconds() / float64(len(results))
+	}
+	if len(lengths) > 0 {
+		sort.Ints(lengths)
+		var sum int
+		for _, l := range lengths {
+			sum += l
+		}
+		res.MeanMoves = float64(sum) / float64(len(lengths))
+		res.MeanNetworkMoves = float64(networkMoves) / float64(len(lengths))
+		if len(lengths)%2 == 0 {
+			res.MedianMoves = float64(lengths[len(lengths)/2-1]+lengths[len(lengths)/2]) / 2
+		} else {
+			res.MedianMoves = float64(lengths[len(lengths)/2])
+		}
+	}
+	return res
+}
+
+func printResults(r *Results, hybrid bool) {
+	fmt.Printf("Solved %d/%d scrambles (%.1f%%)\n", r.Solved, r.Scrambles, r.SolveRate*100)
+	fmt.Printf("  Mean moves: %.1f\n", r.MeanMoves)
+	fmt.Printf("Median moves: %.1f\n", r.MedianMoves)
+	if hybrid {
+		fmt.Printf("Mean network moves: %.1f\n", r.MeanNetworkMoves)
+	}
+	fmt.Println("Furthest stage reached:")
+	for progress := 0; progress <= humancube.MaxProgress; progress++ {
+		name := humancube.ProgressName(progress)
+		fmt.Printf("  %-8s %d\n", name+":", r.Reached[name])
+	}
+	fmt.Printf("Mean time per solve: %.3fs\n", r.MeanSeconds)
+	fmt.Printf("Total time: %.3fs\n", r.TotalSeconds)
+}
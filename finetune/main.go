@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/humancube"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/sgd"
+	"github.com/unixpickle/weakai/rnn"
+	"github.com/unixpickle/weakai/rnn/seqtoseq"
+)
+
+type Options struct {
+	Iterations int
+	Rollouts   int
+	StepSize   float64
+	MaxMoves   int
+	SaveEvery  int
+	Seed       int64
+	Reference  string
+
+	Rewards humancube.RewardParams
+	Style   humancube.Style
+}
+
+func main() {
+	var opts Options
+	flag.IntVar(&opts.Iterations, "iters", 1000, "number of policy updates (0 for no limit)")
+	flag.IntVar(&opts.Rollouts, "rollouts", 64, "random scrambles per update")
+	flag.Float64Var(&opts.StepSize, "step", 1e-4, "Adam step size")
+	flag.IntVar(&opts.MaxMoves, "maxmoves", 150, "maximum moves per rollout")
+	flag.IntVar(&opts.SaveEvery, "save", 50, "save the network every this many updates")
+	flag.Int64Var(&opts.Seed, "seed", time.Now().UnixNano(), "seed for random scrambles")
+	flag.StringVar(&opts.Reference, "ref", "", "reference network for the KL penalty "+
+		"(defaults to the starting network)")
+	flag.Float64Var(&opts.Rewards.SolveReward, "solvereward", 10, "reward for solving the cube")
+	flag.Float64Var(&opts.Rewards.MilestoneReward, "milestone", 1,
+		"reward for every new stage of the solve")
+	flag.Float64Var(&opts.Rewards.MovePenalty, "movepenalty", 0.02, "penalty for every move")
+	flag.Float64Var(&opts.Rewards.KLWeight, "kl", 0.1, "weight of the KL penalty toward "+
+		"the reference network")
+	flag.Float64Var(&opts.Rewards.Discount, "discount", 1, "discount factor for future rewards")
+	humancube.AddStyleFlags(flag.CommandLine, &opts.Style)
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] network_file output_file")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+	}
+	if err := Finetune(flag.Arg(0), flag.Arg(1), &opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Finetune improves a network with REINFORCE on random
+// scrambles.
+func Finetune(netFile, outFile string, opts *Options) error {
+	if opts.Rollouts <= 0 || opts.MaxMoves <= 0 {
+		return errors.New("rollouts and max moves must be positive")
+	}
+	net, err := readNetwork(netFile, opts)
+	if err != nil {
+		return err
+	}
+	refFile := netFile
+	if opts.Reference != "" {
+		refFile = opts.Reference
+	}
+	ref, err := readNetwork(refFile, opts)
+	if err != nil {
+		return errors.New("load reference: " + err.Error())
+	}
+	if len(ref.MoveMap) != len(net.MoveMap) {
+		return errors.New("reference network has different moves")
+	}
+	for move := range net.MoveMap {
+		if _, ok := ref.MoveMap[move]; !ok {
+			return errors.New("reference network is missing move: " + move)
+		}
+	}
+
+	// Rollouts are sampled without dropout, so the
+	// gradient is computed without it as well.
+	net.Dropout(false)
+	ref.Dropout(false)
+
+	gradienter := &sgd.Adam{
+		Gradienter: &seqtoseq.Gradienter{
+			SeqFunc:  &rnn.BlockSeqFunc{B: net.Block},
+			Learner:  net.Block,
+			CostFunc: net.CostFunc(0),
+			MaxLanes: opts.Rollouts,
+		},
+		DecayRate1: 0.9,
+		DecayRate2: 0.999,
+		Damping:    1e-8,
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	rand.Seed(opts.Seed)
+	log.Println("Press ctrl+c to stop early.")
+	for iter := 0; opts.Iterations == 0 || iter < opts.Iterations; iter++ {
+		select {
+		case <-interrupt:
+			log.Println("Stopping early...")
+			return saveNetwork(outFile, net)
+		default:
+		}
+
+		cubes, err := randomCubes(net, opts.Rollouts)
+		if err != nil {
+			return err
+		}
+		rollouts := net.Rollouts(ref, cubes, opts.MaxMoves)
+		logRollouts(iter, rollouts)
+
+		set := net.NewReinforceSet(rollouts, &opts.Rewards)
+		if set.Len() > 0 {
+			gradienter.Gradient(set).AddToVars(-opts.StepSize)
+		}
+
+		if opts.SaveEvery > 0 && (iter+1)%opts.SaveEvery == 0 {
+			if err := saveNetwork(outFile, net); err != nil {
+				return err
+			}
+		}
+	}
+	return saveNetwork(outFile, net)
+}
+
+func readNetwork(path string, opts *Options) (*humancube.Network, error) {
+	net, err := humancube.ReadNetwork(path)
+	if err != nil {
+		return nil, err
+	}
+	net.Style = opts.Style
+	return net, nil
+}
+
+func randomCubes(net *humancube.Network, count int) ([]*gocube.CubieCube, error) {
+	res := make([]*gocube.CubieCube, count)
+	for i := range res {
+		cube := gocube.RandomCubieCube()
+		if err := net.StartCube(&cube); err != nil {
+			return nil, err
+		}
+		res[i] = &cube
+	}
+	return res, nil
+}
+
+// logRollouts prints the solve rate, the mean length of
+// the solves, and the mean KL divergence per move from the
+// reference network.
+func logRollouts(iter int, rollouts []*humancube.Rollout) {
+	var solved, solveMoves, moves int
+	var kl float64
+	for _, r := range rollouts {
+		if r.Solved {
+			solved++
+			solveMoves += len(r.Moves)
+		}
+		for i, logProb := range r.LogProbs {
+			kl += logProb - r.RefLogProbs[i]
+			moves++
+		}
+	}
+	var meanLength float64
+	if solved > 0 {
+		meanLength = float64(solveMoves) / float64(solved)
+	}
+	if moves > 0 {
+		kl /= float64(moves)
+	}
+	log.Printf("iter %d: solved=%d/%d mean_length=%.1f kl=%f", iter, solved, len(rollouts),
+		meanLength, kl)
+}
+
+func saveNetwork(path string, net *humancube.Network) error {
+	log.Println("Saving...")
+	if err := serializer.SaveAny(path, net); err != nil {
+		return errors.New("save network: " + err.Error())
+	}
+	return nil
+}
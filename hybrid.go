@@ -0,0 +1,133 @@
+package humancube
+
+import (
+	"errors"
+	"time"
+
+	"github.com/unixpickle/gocube"
+)
+
+// HybridParams configures FinishSolution.
+type HybridParams struct {
+	// HandOff is the Progress at which the network's moves
+	// are cut off and the classical solver takes over.
+	// With MaxProgress, the network's moves are kept up to
+	// the furthest progress they reach.
+	HandOff int
+
+	// SolverTime is how long the classical solver may
+	// spend looking for shorter solutions after it finds
+	// its first one.
+	SolverTime time.Duration
+
+	// MaxSolverMoves is the maximum length of the
+	// classical solver's solution.
+	MaxSolverMoves int
+}
+
+// HybridHandOff returns the HandOff for a named stage:
+// "cross", "f2l", "oll", or "best" for MaxProgress.
+func HybridHandOff(stage string) (int, error) {
+	switch stage {
+	case "cross":
+		return 1, nil
+	case "f2l":
+		return 5, nil
+	case "oll":
+		return 6, nil
+	case "best":
+		return MaxProgress, nil
+	}
+	return 0, errors.New("unknown hybrid stage: " + stage)
+}
+
+// A HybridSolution is a solution made partly by a network
+// and partly by a classical solver.
+type HybridSolution struct {
+	// NetworkMoves are the moves kept from the network,
+	// made after StartCube.
+	NetworkMoves []string
+
+	// SolverMoves are the classical solver's moves, made
+	// after NetworkMoves.
+	SolverMoves []string
+}
+
+// Moves returns all of the moves of the solution.
+func (h *HybridSolution) Moves() []string {
+	return append(append([]string{}, h.NetworkMoves...), h.SolverMoves...)
+}
+
+// FinishSolution keeps the network's moves from a (possibly
+// unsuccessful) solution up to the point where it either
+// reaches p.HandOff or makes the most progress, and then
+// solves the rest of the cube with gocube's two-phase
+// solver.
+//
+// The cube should already have been passed through
+// StartCube.
+func FinishSolution(c *gocube.CubieCube, s *Solution, p *HybridParams) (*HybridSolution, error) {
+	cube := *c
+	bestProgress := Progress(&cube)
+	var keep int
+	if bestProgress < p.HandOff {
+		for i, move := range s.Moves {
+			if err := Move(&cube, move); err != nil {
+				return nil, err
+			}
+			if progress := Progress(&cube); progress > bestProgress {
+				bestProgress = progress
+				keep = i + 1
+				if progress >= p.HandOff {
+					break
+				}
+			}
+		}
+	}
+
+	res := &HybridSolution{NetworkMoves: s.Moves[:keep]}
+	cube = *c
+	for _, move := range res.NetworkMoves {
+		Move(&cube, move)
+	}
+	if cube.Solved() {
+		return res, nil
+	}
+
+	moves, err := classicalSolve(cube, p)
+	if err != nil {
+		return nil, err
+	}
+	res.SolverMoves = moves
+	return res, nil
+}
+
+func classicalSolve(c gocube.CubieCube, p *HybridParams) ([]string, error) {
+	solver := gocube.NewSolver(c, p.MaxSolverMoves)
+	defer solver.Stop()
+
+	solutions := solver.Solutions()
+	best, ok := <-solutions
+	if !ok {
+		return nil, errors.New("classical solver found no solution")
+	}
+	timeout := time.After(p.SolverTime)
+SearchLoop:
+	for {
+		select {
+		case solution, ok := <-solutions:
+			if !ok {
+				break SearchLoop
+			}
+			best = solution
+		case <-timeout:
+			break SearchLoop
+		}
+	}
+
+	res := make([]string, len(best))
+	for i, move := range best {
+		res[i] = move.String()
+	}
+	return res, nil
+}
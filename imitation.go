@@ -0,0 +1,223 @@
+package humancube
+
+import (
+	"math"
+	"strings"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// These are the move types reported by ImitationMetrics.
+const (
+	FaceMove     = "face"
+	WideMove     = "wide"
+	SliceMove    = "slice"
+	RotationMove = "rotation"
+)
+
+// MoveType classifies a move as a FaceMove, WideMove,
+// SliceMove, or RotationMove.
+func MoveType(move string) string {
+	if move == "" {
+		return FaceMove
+	}
+	switch move[0] {
+	case 'x', 'y', 'z':
+		return RotationMove
+	case 'M', 'E', 'S':
+		return SliceMove
+	}
+	if _, ok := hybridMoves[move[:1]]; ok {
+		return WideMove
+	}
+	return FaceMove
+}
+
+// MoveAccuracy accumulates how well a network predicts a
+// set of human moves.
+type MoveAccuracy struct {
+	Moves int
+	Top1  int
+	Top5  int
+
+	// LogProb is the total log probability the network
+	// assigned to the human moves.
+	LogProb float64
+}
+
+// Top1Accuracy returns the fraction of moves which were
+// the network's likeliest move.
+func (m *MoveAccuracy) Top1Accuracy() float64 {
+	if m.Moves == 0 {
+		return 0
+	}
+	return float64(m.Top1) / float64(m.Moves)
+}
+
+// Top5Accuracy returns the fraction of moves which were
+// among the network's five likeliest moves.
+func (m *MoveAccuracy) Top5Accuracy() float64 {
+	if m.Moves == 0 {
+		return 0
+	}
+	return float64(m.Top5) / float64(m.Moves)
+}
+
+// Perplexity returns the exponentiated mean negative log
+// probability of the moves.
+func (m *MoveAccuracy) Perplexity() float64 {
+	if m.Moves == 0 {
+		return 0
+	}
+	return math.Exp(-m.LogProb / float64(m.Moves))
+}
+
+func (m *MoveAccuracy) add(rank int, logProb float64) {
+	m.Moves++
+	if rank < 1 {
+		m.Top1++
+	}
+	if rank < 5 {
+		m.Top5++
+	}
+	m.LogProb += logProb
+}
+
+// ImitationMetrics measures a network's next-move
+// predictions on human solves under teacher forcing.
+type ImitationMetrics struct {
+	Total MoveAccuracy
+
+	// Phases breaks down the moves by the Phase of the cube
+	// before each move.
+	Phases map[string]*MoveAccuracy
+
+	// MoveTypes breaks down the moves by their MoveType.
+	MoveTypes map[string]*MoveAccuracy
+
+	// Skipped is the number of samples which were cut short
+	// because they contained a move the network cannot
+	// make.
+	Skipped int
+}
+
+// ValidationSamples returns the samples which training
+// holds out for validation when the network is trained on
+// the given samples.
+// For canonical networks, the result has been
+// canonicalized, so its starting cubes have already been
+// passed through StartCube.
+func (n *Network) ValidationSamples(s *SampleSet) (*SampleSet, error) {
+	s = s.Copy().(*SampleSet)
+	if n.Canonical {
+		if err := s.Canonicalize(n.Reference); err != nil {
+			return nil, err
+		}
+	}
+	validation, _ := n.SplitSamples(s)
+	return validation, nil
+}
+
+// SplitSamples splits samples, which must already have
+// been converted for the network, the way the network's
+// Split says.
+func (n *Network) SplitSamples(s *SampleSet) (validation, training *SampleSet) {
+	split := n.Split
+	if split == nil {
+		split = DefaultSplitOptions()
+	}
+	return split.Split(s)
+}
+
+// Imitate feeds the human moves of every sample to the
+// network and measures how well it predicts each move.
+// Each sample's Style is used to condition the network.
+//
+// The samples' starting cubes should already have been
+// passed through StartCube, and samples are evaluated
+// batchSize at a time.
+func (n *Network) Imitate(s *SampleSet, batchSize int) *ImitationMetrics {
+	res := &ImitationMetrics{
+		Phases:    map[string]*MoveAccuracy{},
+		MoveTypes: map[string]*MoveAccuracy{},
+	}
+	for i := 0; i < len(s.Samples); i += batchSize {
+		end := i + batchSize
+		if end > len(s.Samples) {
+			end = len(s.Samples)
+		}
+		n.imitateBatch(s.Samples[i:end], res)
+	}
+	return res
+}
+
+func (n *Network) imitateBatch(samples []Sample, m *ImitationMetrics) {
+	var states []*PolicyState
+	var moves [][]string
+	for _, sample := range samples {
+		sampleMoves := strings.Fields(sample.Moves)
+		if len(sampleMoves) == 0 {
+			continue
+		}
+		state := n.StartState(sample.Start)
+		state.style = sample.Style()
+		states = append(states, state)
+		moves = append(moves, sampleMoves)
+	}
+
+	for len(states) > 0 {
+		evals := n.Evaluate(states)
+		var nextStates []*PolicyState
+		var nextMoves [][]string
+		for i, state := range states {
+			move := moves[i][len(state.Moves)]
+			moveIdx, ok := n.MoveMap[move]
+			if !ok {
+				m.Skipped++
+				continue
+			}
+			policy := evals[i].Policy
+			rank := policyRank(policy, moveIdx)
+			phase := DetectPhase(&state.Cube).String()
+			moveType := MoveType(move)
+			for _, accuracy := range []*MoveAccuracy{
+				&m.Total,
+				m.accuracy(m.Phases, phase),
+				m.accuracy(m.MoveTypes, moveType),
+			} {
+				accuracy.add(rank, policy[moveIdx])
+			}
+
+			child := state.Child(evals[i], move, moveIdx)
+			if len(child.Moves) < len(moves[i]) {
+				nextStates = append(nextStates, child)
+				nextMoves = append(nextMoves, moves[i])
+			}
+		}
+		states = nextStates
+		moves = nextMoves
+	}
+}
+
+func (m *ImitationMetrics) accuracy(groups map[string]*MoveAccuracy,
+	name string) *MoveAccuracy {
+	if res, ok := groups[name]; ok {
+		return res
+	}
+	res := &MoveAccuracy{}
+	groups[name] = res
+	return res
+}
+
+// policyRank counts the moves which a policy makes
+// strictly likelier than the move at idx, so that tied
+// moves share a rank.
+func policyRank(policy linalg.Vector, idx int) int {
+	var rank int
+	for _, logProb := range policy {
+		if logProb > policy[idx] {
+			rank++
+		}
+	}
+	return rank
+}
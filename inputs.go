@@ -0,0 +1,87 @@
+package humancube
+
+import (
+	"strings"
+
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// DefaultMethods lists the solving methods which networks
+// can be conditioned on by default.
+var DefaultMethods = []string{"CFOP", "Roux", "ZZ"}
+
+// An InputConfig describes the inputs a network receives
+// alongside the encoded cube at each timestep.
+type InputConfig struct {
+	// History is the number of previous moves which are
+	// given to the network as one-hot vectors.
+	// Moves before the start of a sample are all zeros.
+	History int
+
+	// Phase adds a one-hot vector of the detected solve
+	// phase (see DetectPhase).
+	Phase bool
+
+	// Methods lists the solving methods to give to the
+	// network as a one-hot vector.
+	// Samples with other methods get a zero vector.
+	Methods []string
+
+	// Solvers lists the solvers to give to the network as
+	// a one-hot vector, which the network's first layer
+	// turns into a learned embedding per solver.
+	// Samples from other solvers get a zero vector.
+	Solvers []string
+}
+
+// Size returns the number of extra inputs, given the
+// number of moves in the network's MoveMap.
+func (i *InputConfig) Size(numMoves int) int {
+	res := i.History*numMoves + len(i.Methods) + len(i.Solvers)
+	if i.Phase {
+		res += NumPhases
+	}
+	return res
+}
+
+// A Style specifies how a solve is performed, for networks
+// which are conditioned on methods or solvers.
+type Style struct {
+	Method string
+	Solver string
+}
+
+// inputVector builds a network input from a cube and the
+// moves which led to it.
+func inputVector(e Encoder, c *gocube.CubieCube, moveMap map[string]int, inputs *InputConfig,
+	moves []string, style Style) linalg.Vector {
+	res := encoderOrDefault(e).Encode(c)
+	for i := 1; i <= inputs.History; i++ {
+		oneHot := make(linalg.Vector, len(moveMap))
+		if i <= len(moves) {
+			if idx, ok := moveMap[moves[len(moves)-i]]; ok {
+				oneHot[idx] = 1
+			}
+		}
+		res = append(res, oneHot...)
+	}
+	if inputs.Phase {
+		oneHot := make(linalg.Vector, NumPhases)
+		oneHot[DetectPhase(c)] = 1
+		res = append(res, oneHot...)
+	}
+	res = append(res, nameOneHot(inputs.Methods, style.Method)...)
+	res = append(res, nameOneHot(inputs.Solvers, style.Solver)...)
+	return res
+}
+
+func nameOneHot(names []string, name string) linalg.Vector {
+	res := make(linalg.Vector, len(names))
+	for i, x := range names {
+		if strings.EqualFold(x, name) {
+			res[i] = 1
+		}
+	}
+	return res
+}
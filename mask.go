@@ -0,0 +1,99 @@
+package humancube
+
+import (
+	"math"
+
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A MoveMask rules out moves which are useless or which a
+// human would never make, so that decoding does not waste
+// moves on them.
+type MoveMask struct {
+	// NoRepeatFace prevents turning the same face (or
+	// rotating about the same axis) twice in a row, which
+	// humans would write as a single move and which
+	// includes undoing the previous move.
+	NoRepeatFace bool
+
+	// MaxRotations, if non-zero, is the maximum number of
+	// rotations in a row.
+	MaxRotations int
+
+	// NoPhaseStartRotation prevents rotating as the first
+	// move of the solve or the first move after a phase is
+	// completed.
+	NoPhaseStartRotation bool
+}
+
+// Allowed checks if a move may be made from a state.
+func (m *MoveMask) Allowed(state *PolicyState, move string) bool {
+	var last string
+	if len(state.Moves) > 0 {
+		last = state.Moves[len(state.Moves)-1]
+	}
+	if m.NoRepeatFace && sameFace(move, last) {
+		return false
+	}
+	if MoveType(move) != RotationMove {
+		return true
+	}
+	if m.MaxRotations > 0 {
+		var rotations int
+		for i := len(state.Moves) - 1; i >= 0; i-- {
+			if MoveType(state.Moves[i]) != RotationMove {
+				break
+			}
+			rotations++
+		}
+		if rotations >= m.MaxRotations {
+			return false
+		}
+	}
+	if m.NoPhaseStartRotation {
+		if last == "" {
+			return false
+		}
+		prev := state.Cube
+		MoveInverse(&prev, last)
+		if DetectPhase(&prev) != DetectPhase(&state.Cube) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply masks a policy from Evaluate, giving disallowed
+// moves a log probability of -Inf and renormalizing the
+// rest.
+// If every move is disallowed, the policy is returned
+// unchanged.
+func (m *MoveMask) Apply(state *PolicyState, policy linalg.Vector,
+	moveNames []string) linalg.Vector {
+	res := make(linalg.Vector, len(policy))
+	maxLogProb := math.Inf(-1)
+	for i, logProb := range policy {
+		if m.Allowed(state, moveNames[i]) {
+			res[i] = logProb
+			maxLogProb = math.Max(maxLogProb, logProb)
+		} else {
+			res[i] = math.Inf(-1)
+		}
+	}
+	if math.IsInf(maxLogProb, -1) {
+		return policy
+	}
+	var total float64
+	for _, logProb := range res {
+		total += math.Exp(logProb - maxLogProb)
+	}
+	logTotal := maxLogProb + math.Log(total)
+	for i := range res {
+		res[i] -= logTotal
+	}
+	return res
+}
+
+func sameFace(move1, move2 string) bool {
+	return len(move1) > 0 && len(move2) > 0 && move1[0] == move2[0]
+}
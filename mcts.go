@@ -0,0 +1,199 @@
+package humancube
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/unixpickle/gocube"
+)
+
+// These are the ways MCTS can estimate the value of a
+// state.
+//
+// ProgressValue uses Progress, and NetworkValue uses the
+// network's value head.
+const (
+	ProgressValue = "progress"
+	NetworkValue  = "network"
+)
+
+// MCTSParams configures MCTS.
+type MCTSParams struct {
+	// Simulations is the number of simulations to run
+	// before committing to each move.
+	Simulations int
+
+	// TimeLimit bounds the total search time.
+	// Once it runs out, the remaining moves are chosen
+	// greedily by the network.
+	// If it is 0, there is no limit.
+	TimeLimit time.Duration
+
+	// Exploration scales the prior term of the PUCT rule.
+	Exploration float64
+
+	// MaxMoves is the maximum solution length.
+	MaxMoves int
+
+	// Value is ProgressValue or NetworkValue.
+	// NetworkValue falls back to ProgressValue for
+	// networks without a value head.
+	Value string
+}
+
+// Validate checks that the parameters are usable.
+func (p *MCTSParams) Validate() error {
+	if p.Value != ProgressValue && p.Value != NetworkValue {
+		return errors.New("unknown MCTS value: " + p.Value)
+	}
+	if p.Exploration < 0 {
+		return errors.New("MCTS exploration must not be negative")
+	}
+	return nil
+}
+
+type mctsNode struct {
+	state  *PolicyState
+	eval   *Evaluation
+	value  float64
+	solved bool
+
+	children    map[int]*mctsNode
+	visits      []int
+	totalValues []float64
+	totalVisits int
+}
+
+// MCTS solves a cube with Monte Carlo tree search, using
+// the network's move distribution as a prior.
+// Leaf values range from 0 to 1, where 1 means solved.
+//
+// The cube should already have been passed through
+// StartCube.
+func (n *Network) MCTS(c *gocube.CubieCube, p *MCTSParams) *Solution {
+	moveNames := n.MoveNames()
+	root := n.mctsLeaf(n.StartState(c), p)
+	var deadline time.Time
+	if p.TimeLimit != 0 {
+		deadline = time.Now().Add(p.TimeLimit)
+	}
+
+	for len(root.state.Moves) < p.MaxMoves && !root.solved {
+		for i := 0; i < p.Simulations; i++ {
+			if !deadline.IsZero() && time.Now().After(deadline) {
+				break
+			}
+			n.mctsSimulate(root, moveNames, p)
+		}
+		root = n.mctsChild(root, root.bestMove(), moveNames, p)
+	}
+
+	return &Solution{
+		Moves:   root.state.Moves,
+		LogProb: root.state.LogProb,
+		Solved:  root.solved,
+	}
+}
+
+func (n *Network) mctsSimulate(root *mctsNode, moveNames []string, p *MCTSParams) {
+	path := []*mctsNode{root}
+	var moves []int
+	node := root
+	for !node.solved && len(node.state.Moves) < p.MaxMoves {
+		move := node.selectMove(p.Exploration)
+		moves = append(moves, move)
+		child, ok := node.children[move]
+		if !ok {
+			node = n.mctsChild(node, move, moveNames, p)
+			path = append(path, node)
+			break
+		}
+		node = child
+		path = append(path, node)
+	}
+
+	value := node.value
+	for i, move := range moves {
+		parent := path[i]
+		parent.visits[move]++
+		parent.totalValues[move] += value
+		parent.totalVisits++
+	}
+}
+
+func (n *Network) mctsChild(node *mctsNode, move int, moveNames []string,
+	p *MCTSParams) *mctsNode {
+	if child, ok := node.children[move]; ok {
+		return child
+	}
+	child := n.mctsLeaf(node.state.Child(node.eval, moveNames[move], move), p)
+	node.children[move] = child
+	return child
+}
+
+func (n *Network) mctsLeaf(state *PolicyState, p *MCTSParams) *mctsNode {
+	res := &mctsNode{
+		state:    state,
+		children: map[int]*mctsNode{},
+	}
+	if state.Cube.Solved() {
+		res.solved = true
+		res.value = 1
+		return res
+	}
+	res.eval = n.Evaluate([]*PolicyState{state})[0]
+	res.visits = make([]int, len(res.eval.Policy))
+	res.totalValues = make([]float64, len(res.eval.Policy))
+	progress := float64(Progress(&state.Cube))
+	res.value = progress / MaxProgress
+	if p.Value == NetworkValue {
+		if remaining, ok := n.Value(res.eval.Output); ok {
+			closeness := 1 / (1 + math.Max(0, remaining)/valueScale)
+			if n.Config.Value == ValuePhase {
+				// Moves left in the phase only rank states
+				// within the same phase.
+				res.value = (progress + closeness) / (MaxProgress + 1)
+			} else {
+				res.value = closeness
+			}
+		}
+	}
+	return res
+}
+
+// selectMove picks the move to explore with the PUCT rule.
+func (m *mctsNode) selectMove(exploration float64) int {
+	bestMove := 0
+	bestScore := math.Inf(-1)
+	sqrtTotal := math.Sqrt(float64(m.totalVisits) + 1)
+	for move, logProb := range m.eval.Policy {
+		if math.IsInf(logProb, -1) {
+			continue
+		}
+		var q float64
+		if m.visits[move] > 0 {
+			q = m.totalValues[move] / float64(m.visits[move])
+		}
+		u := exploration * math.Exp(logProb) * sqrtTotal / float64(1+m.visits[move])
+		if q+u > bestScore {
+			bestScore = q + u
+			bestMove = move
+		}
+	}
+	return bestMove
+}
+
+// bestMove picks the most visited move, breaking ties by
+// the network's prior.
+func (m *mctsNode) bestMove() int {
+	bestMove := 0
+	for move := range m.visits {
+		if m.visits[move] > m.visits[bestMove] ||
+			(m.visits[move] == m.visits[bestMove] &&
+				m.eval.Policy[move] > m.eval.Policy[bestMove]) {
+			bestMove = move
+		}
+	}
+	return bestMove
+}
@@ -0,0 +1,160 @@
+package humancube
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var metricsColumns = []string{"batch", "epoch", "train_cost", "validation_cost", "accuracy",
+	"step_size", "seconds"}
+
+// A MetricsRow records the state of training at one
+// batch.
+type MetricsRow struct {
+	Batch int `json:"batch"`
+	Epoch int `json:"epoch"`
+
+	// TrainCost and ValidationCost are mean costs per
+	// sample.
+	TrainCost      float64 `json:"train_cost"`
+	ValidationCost float64 `json:"validation_cost"`
+
+	// Accuracy is the teacher-forced top-1 accuracy on the
+	// validation samples.
+	Accuracy float64 `json:"accuracy"`
+
+	StepSize float64 `json:"step_size"`
+
+	// Seconds is the training time so far.
+	Seconds float64 `json:"seconds"`
+}
+
+func (m *MetricsRow) fields() []float64 {
+	return []float64{float64(m.Batch), float64(m.Epoch), m.TrainCost, m.ValidationCost,
+		m.Accuracy, m.StepSize, m.Seconds}
+}
+
+func (m *MetricsRow) setFields(values []float64) {
+	m.Batch = int(values[0])
+	m.Epoch = int(values[1])
+	m.TrainCost = values[2]
+	m.ValidationCost = values[3]
+	m.Accuracy = values[4]
+	m.StepSize = values[5]
+	m.Seconds = values[6]
+}
+
+// A MetricsLog writes MetricsRows to a CSV file or, for
+// any other extension than ".csv", a JSON Lines file.
+type MetricsLog struct {
+	file    *os.File
+	csv     *csv.Writer
+	encoder *json.Encoder
+}
+
+// OpenMetricsLog opens a metrics log, appending to it if
+// it already exists.
+func OpenMetricsLog(path string) (*MetricsLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	res := &MetricsLog{file: f}
+	if !isCSV(path) {
+		res.encoder = json.NewEncoder(f)
+		return res, nil
+	}
+	res.csv = csv.NewWriter(f)
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size() == 0 {
+		res.csv.Write(metricsColumns)
+		res.csv.Flush()
+		if err := res.csv.Error(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Write adds a row to the log.
+func (m *MetricsLog) Write(row *MetricsRow) error {
+	if m.encoder != nil {
+		return m.encoder.Encode(row)
+	}
+	var record []string
+	for _, value := range row.fields() {
+		record = append(record, strconv.FormatFloat(value, 'g', -1, 64))
+	}
+	m.csv.Write(record)
+	m.csv.Flush()
+	return m.csv.Error()
+}
+
+// Close closes the log's file.
+func (m *MetricsLog) Close() error {
+	return m.file.Close()
+}
+
+// ReadMetricsLog reads every row from a metrics log.
+func ReadMetricsLog(path string) ([]*MetricsRow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var res []*MetricsRow
+	if !isCSV(path) {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) == "" {
+				continue
+			}
+			var row MetricsRow
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				return nil, errors.New("parse metrics: " + err.Error())
+			}
+			res = append(res, &row)
+		}
+		return res, scanner.Err()
+	}
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, errors.New("parse metrics: " + err.Error())
+	}
+	for i, record := range records {
+		if i == 0 {
+			continue
+		}
+		if len(record) != len(metricsColumns) {
+			return nil, errors.New("parse metrics: wrong number of columns")
+		}
+		values := make([]float64, len(record))
+		for j, field := range record {
+			values[j], err = strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, errors.New("parse metrics: " + err.Error())
+			}
+		}
+		var row MetricsRow
+		row.setFields(values)
+		res = append(res, &row)
+	}
+	return res, nil
+}
+
+func isCSV(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".csv"
+}
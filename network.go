@@ -3,18 +3,15 @@ package humancube
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 
+	"github.com/unixpickle/gocube"
 	"github.com/unixpickle/num-analysis/linalg"
 	"github.com/unixpickle/serializer"
 	"github.com/unixpickle/weakai/neuralnet"
 	"github.com/unixpickle/weakai/rnn"
 )
 
-const (
-	hiddenSize             = 150
-	dropoutKeepProbability = 0.5
-)
-
 func init() {
 	var n Network
 	serializer.RegisterTypedDeserializer(n.SerializerType(), DeserializeNetwork)
@@ -23,33 +20,64 @@ func init() {
 type Network struct {
 	Block   rnn.StackedBlock
 	MoveMap map[string]int
+	Encoder Encoder
+	Config  *NetworkConfig
+
+	// Style is the solving style which conditioned
+	// networks are asked to imitate.
+	// It is not saved with the network.
+	Style Style
+
+	// Mask, if non-nil, restricts the moves the network may
+	// make while decoding.
+	// It is not saved with the network.
+	Mask *MoveMask
+
+	// Canonical is set if the network was trained on
+	// canonical samples (see CanonicalSample) with the
+	// given reference rotations.
+	Canonical bool
+	Reference string
+
+	// Split is the split the network was trained with.
+	// If it is nil, DefaultSplitOptions is assumed.
+	Split *SplitOptions
+
+	// outputDropoutOnly is set for networks saved before
+	// their architecture was configurable, whose Dropout
+	// method only toggled the dropout before the output
+	// layer.
+	outputDropoutOnly bool
 }
 
-func NewNetwork(inSize int, moveMap map[string]int) *Network {
-	netLayer := neuralnet.Network{
-		&neuralnet.DropoutLayer{
-			KeepProbability: dropoutKeepProbability,
-			Training:        false,
-		},
-		&neuralnet.DenseLayer{InputCount: hiddenSize, OutputCount: len(moveMap)},
-		&neuralnet.LogSoftmaxLayer{},
-	}
-	netLayer.Randomize()
+// networkMetadata stores the settings of a Network which
+// are not part of its Block.
+type networkMetadata struct {
+	Encoder   string
+	Config    *NetworkConfig
+	Canonical bool
+	Reference string
+	Split     *SplitOptions `json:",omitempty"`
 
-	lstmNet1 := rnn.NewLSTM(inSize, hiddenSize)
-	midDropout := rnn.NewNetworkBlock(neuralnet.Network{
-		&neuralnet.DropoutLayer{
-			KeepProbability: dropoutKeepProbability,
-			Training:        false,
-		},
-	}, 0)
-	lstmNet2 := rnn.NewLSTM(hiddenSize, hiddenSize)
-	outputFilter := rnn.NewNetworkBlock(netLayer, 0)
+	OutputDropoutOnly bool `json:",omitempty"`
+}
 
+// NewNetwork creates a randomly initialized Network.
+// If config is nil, DefaultNetworkConfig is used.
+func NewNetwork(encoder Encoder, moveMap map[string]int, config *NetworkConfig) (*Network,
+	error) {
+	if config == nil {
+		config = DefaultNetworkConfig()
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	return &Network{
-		Block:   rnn.StackedBlock{lstmNet1, midDropout, lstmNet2, outputFilter},
+		Block:   config.block(config.InputSize(encoder, len(moveMap)), len(moveMap)),
 		MoveMap: moveMap,
-	}
+		Encoder: encoder,
+		Config:  config,
+	}, nil
 }
 
 func ReadNetwork(path string) (*Network, error) {
@@ -59,21 +87,49 @@ func ReadNetwork(path string) (*Network, error) {
 }
 
 func DeserializeNetwork(d []byte) (*Network, error) {
-	var moveData serializer.Bytes
+	var moveData, metaData serializer.Bytes
 	var net rnn.StackedBlock
 
-	if err := serializer.DeserializeAny(d, &moveData, &net); err != nil {
+	// Networks saved before metadata was added only
+	// contain a move map and a block, and networks saved
+	// before the architecture was configurable have no
+	// config.
+	metadata := networkMetadata{Encoder: DefaultEncoder}
+	if err := serializer.DeserializeAny(d, &moveData, &net, &metaData); err == nil {
+		if err := json.Unmarshal(metaData, &metadata); err != nil {
+			return nil, errors.New("read metadata: " + err.Error())
+		}
+	} else if err := serializer.DeserializeAny(d, &moveData, &net); err != nil {
 		return nil, err
 	}
+	if metadata.Config == nil {
+		metadata.Config = DefaultNetworkConfig()
+		metadata.OutputDropoutOnly = true
+	}
+
 	var moveMap map[string]int
 	if err := json.Unmarshal(moveData, &moveMap); err != nil {
 		return nil, errors.New("read move map: " + err.Error())
 	}
-	return &Network{Block: net, MoveMap: moveMap}, nil
+	encoder, err := EncoderForName(metadata.Encoder)
+	if err != nil {
+		return nil, err
+	}
+	return &Network{
+		Block:     net,
+		MoveMap:   moveMap,
+		Encoder:   encoder,
+		Config:    metadata.Config,
+		Canonical: metadata.Canonical,
+		Reference: metadata.Reference,
+		Split:     metadata.Split,
+
+		outputDropoutOnly: metadata.OutputDropoutOnly,
+	}, nil
 }
 
 func (n *Network) OutputMove(out linalg.Vector) string {
-	_, idx := out.Max()
+	_, idx := n.Policy(out).Max()
 	for m, i := range n.MoveMap {
 		if i == idx {
 			return m
@@ -82,20 +138,105 @@ func (n *Network) OutputMove(out linalg.Vector) string {
 	return "?"
 }
 
+// Policy returns the log probabilities of each move from
+// an output of the network.
+func (n *Network) Policy(out linalg.Vector) linalg.Vector {
+	return out[:len(n.MoveMap)]
+}
+
+// Value returns the number of moves the value head
+// predicts from an output of the network.
+// It returns false if the network has no value head.
+func (n *Network) Value(out linalg.Vector) (float64, bool) {
+	if len(out) == len(n.MoveMap) {
+		return 0, false
+	}
+	return out[len(n.MoveMap)] * valueScale, true
+}
+
+// CostFunc returns the cost function for training the
+// network, where valueWeight scales the cost of the value
+// head if there is one.
+func (n *Network) CostFunc(valueWeight float64) neuralnet.CostFunc {
+	if n.Config.Value == ValueNone {
+		return neuralnet.DotCost{}
+	}
+	return &PolicyValueCost{MoveCount: len(n.MoveMap), ValueWeight: valueWeight}
+}
+
+// InputVector generates the network's input for a cube,
+// given the moves the network has made so far.
+func (n *Network) InputVector(c *gocube.CubieCube, moves []string) linalg.Vector {
+	return inputVector(n.Encoder, c, n.MoveMap, &n.Config.InputConfig, moves, n.Style)
+}
+
+// StartCube converts a scrambled cube, held as it was
+// for the scramble, into the state the network expects to
+// start solving from.
+func (n *Network) StartCube(c *gocube.CubieCube) error {
+	if !n.Canonical {
+		return nil
+	}
+	for _, rotation := range strings.Fields(n.Reference) {
+		if err := Move(c, rotation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SolutionMoves converts the moves the network made after
+// StartCube into moves that solve the original cube.
+func (n *Network) SolutionMoves(moves []string) []string {
+	if !n.Canonical {
+		return moves
+	}
+	return append(strings.Fields(n.Reference), moves...)
+}
+
 func (n *Network) Serialize() ([]byte, error) {
 	moveData, err := json.Marshal(n.MoveMap)
 	if err != nil {
 		return nil, err
 	}
-	return serializer.SerializeAny(serializer.Bytes(moveData), n.Block)
+	metaData, err := json.Marshal(networkMetadata{
+		Encoder:   encoderOrDefault(n.Encoder).Name(),
+		Config:    n.Config,
+		Canonical: n.Canonical,
+		Reference: n.Reference,
+		Split:     n.Split,
+
+		OutputDropoutOnly: n.outputDropoutOnly,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return serializer.SerializeAny(serializer.Bytes(moveData), n.Block,
+		serializer.Bytes(metaData))
 }
 
 func (n *Network) SerializerType() string {
 	return "github.com/unixpickle/humancube.Network"
 }
 
+// Dropout enables or disables every dropout layer.
+// For networks saved before the architecture was
+// configurable, only the dropout before the output layer
+// is affected, as it always was.
 func (n *Network) Dropout(on bool) {
-	net := n.Block[len(n.Block)-1].(*rnn.NetworkBlock).Network()
-	dropout := net[0].(*neuralnet.DropoutLayer)
-	dropout.Training = on
+	blocks := n.Block
+	if n.outputDropoutOnly {
+		blocks = blocks[len(blocks)-1:]
+	}
+	for _, block := range blocks {
+		netBlock, ok := block.(*rnn.NetworkBlock)
+		if !ok {
+			continue
+		}
+		for _, layer := range netBlock.Network() {
+			if dropout, ok := layer.(*neuralnet.DropoutLayer); ok {
+				dropout.Training = on
+			}
+		}
+	}
 }
@@ -0,0 +1,184 @@
+package humancube
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/unixpickle/weakai/neuralnet"
+	"github.com/unixpickle/weakai/rnn"
+)
+
+// These are the cell types a NetworkConfig may use.
+//
+// DenseCell makes a feed-forward network of tanh layers
+// which only sees the current input, plus any history
+// inputs.
+const (
+	LSTMCell    = "lstm"
+	GRUCell     = "gru"
+	VanillaCell = "vanilla"
+	DenseCell   = "dense"
+)
+
+// A NetworkConfig describes the architecture of a Network.
+type NetworkConfig struct {
+	// PreLayers lists the sizes of tanh dense layers which
+	// are applied to every input before the recurrent
+	// layers.
+	PreLayers []int
+
+	// Cell is the type of the hidden layers.
+	Cell string
+
+	// HiddenSizes lists the output size of each hidden
+	// layer.
+	HiddenSizes []int
+
+	// Dropout lists the keep probability for the dropout
+	// after each hidden layer.
+	// Layers without an entry use the last entry, and a
+	// keep probability of 1 disables dropout.
+	Dropout []float64
+
+	// InputConfig specifies the inputs which are given to
+	// the network alongside the cube state.
+	InputConfig
+
+	// OutputConfig specifies what the network predicts
+	// alongside the next move.
+	OutputConfig
+}
+
+// DefaultNetworkConfig returns the architecture that is
+// used when none is specified.
+func DefaultNetworkConfig() *NetworkConfig {
+	return &NetworkConfig{
+		Cell:        LSTMCell,
+		HiddenSizes: []int{150, 150},
+		Dropout:     []float64{0.5, 0.5},
+	}
+}
+
+// Validate checks that the configuration describes a
+// valid architecture.
+func (c *NetworkConfig) Validate() error {
+	switch c.Cell {
+	case LSTMCell, GRUCell, VanillaCell, DenseCell:
+	default:
+		return errors.New("unknown cell type: " + c.Cell)
+	}
+	if len(c.HiddenSizes) == 0 {
+		return errors.New("no hidden layers")
+	}
+	if c.History < 0 {
+		return errors.New("negative history size")
+	}
+	if err := c.OutputConfig.Validate(); err != nil {
+		return err
+	}
+	if len(c.Dropout) > len(c.HiddenSizes) {
+		return fmt.Errorf("expected at most %d dropout values but got %d", len(c.HiddenSizes),
+			len(c.Dropout))
+	}
+	for _, size := range append(append([]int{}, c.PreLayers...), c.HiddenSizes...) {
+		if size <= 0 {
+			return fmt.Errorf("invalid layer size: %d", size)
+		}
+	}
+	for _, keep := range c.Dropout {
+		if keep <= 0 || keep > 1 {
+			return fmt.Errorf("invalid keep probability: %f", keep)
+		}
+	}
+	return nil
+}
+
+// InputSize returns the size of the network's input
+// vectors.
+func (c *NetworkConfig) InputSize(e Encoder, numMoves int) int {
+	return e.Size() + c.InputConfig.Size(numMoves)
+}
+
+// block creates a randomly initialized block for the
+// architecture.
+func (c *NetworkConfig) block(inSize, numMoves int) rnn.StackedBlock {
+	var res rnn.StackedBlock
+
+	if len(c.PreLayers) > 0 {
+		var preNet neuralnet.Network
+		lastSize := inSize
+		for _, size := range c.PreLayers {
+			preNet = append(preNet, &neuralnet.DenseLayer{InputCount: lastSize, OutputCount: size},
+				&neuralnet.HyperbolicTangent{})
+			lastSize = size
+		}
+		preNet.Randomize()
+		res = append(res, rnn.NewNetworkBlock(preNet, 0))
+		inSize = lastSize
+	}
+
+	for i, size := range c.HiddenSizes {
+		res = append(res, c.cell(inSize, size))
+		inSize = size
+		if keep := c.keepProbability(i); i+1 < len(c.HiddenSizes) && keep < 1 {
+			res = append(res, rnn.NewNetworkBlock(neuralnet.Network{
+				&neuralnet.DropoutLayer{KeepProbability: keep},
+			}, 0))
+		}
+	}
+
+	var outNet neuralnet.Network
+	if keep := c.keepProbability(len(c.HiddenSizes) - 1); keep < 1 {
+		outNet = append(outNet, &neuralnet.DropoutLayer{KeepProbability: keep})
+	}
+	outNet = append(outNet, &neuralnet.DenseLayer{
+		InputCount:  inSize,
+		OutputCount: c.OutputConfig.Size(numMoves),
+	})
+	if c.Value == ValueNone {
+		outNet = append(outNet, &neuralnet.LogSoftmaxLayer{})
+	} else {
+		outNet = append(outNet, &PolicyValueLayer{MoveCount: numMoves})
+	}
+	outNet.Randomize()
+	res = append(res, rnn.NewNetworkBlock(outNet, 0))
+
+	return res
+}
+
+// keepProbability returns the dropout keep probability
+// after a hidden layer.
+func (c *NetworkConfig) keepProbability(layer int) float64 {
+	if len(c.Dropout) == 0 {
+		return 1
+	} else if layer >= len(c.Dropout) {
+		return c.Dropout[len(c.Dropout)-1]
+	}
+	return c.Dropout[layer]
+}
+
+func (c *NetworkConfig) cell(inSize, outSize int) rnn.Block {
+	switch c.Cell {
+	case GRUCell:
+		return rnn.NewGRU(inSize, outSize)
+	case DenseCell:
+		net := neuralnet.Network{
+			&neuralnet.DenseLayer{InputCount: inSize, OutputCount: outSize},
+			&neuralnet.HyperbolicTangent{},
+		}
+		net.Randomize()
+		return rnn.NewNetworkBlock(net, 0)
+	case VanillaCell:
+		// The network block's input is the input followed by
+		// the state, and its output is the output followed by
+		// the new state.
+		net := neuralnet.Network{
+			&neuralnet.DenseLayer{InputCount: inSize + outSize, OutputCount: outSize * 2},
+			&neuralnet.HyperbolicTangent{},
+		}
+		net.Randomize()
+		return rnn.NewNetworkBlock(net, outSize)
+	default:
+		return rnn.NewLSTM(inSize, outSize)
+	}
+}
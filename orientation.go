@@ -0,0 +1,214 @@
+package humancube
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// DefaultReference is the reference orientation used for
+// canonical samples by default.
+// It puts the top color of a WCA scramble (typically the
+// cross color) on the bottom.
+const DefaultReference = "z2"
+
+const faceNames = "UDFBRL"
+
+// rotationSources maps each rotation to the face each face
+// comes from when the rotation is performed once.
+var rotationSources = map[byte]map[byte]byte{
+	'x': {'U': 'F', 'F': 'D', 'D': 'B', 'B': 'U', 'R': 'R', 'L': 'L'},
+	'y': {'F': 'R', 'R': 'B', 'B': 'L', 'L': 'F', 'U': 'U', 'D': 'D'},
+	'z': {'U': 'L', 'L': 'D', 'D': 'R', 'R': 'U', 'F': 'F', 'B': 'B'},
+}
+
+// A Frame keeps track of a cube's orientation by mapping
+// each face of the cube, as it is currently held, to the
+// face it occupies in a fixed reference orientation.
+type Frame map[byte]byte
+
+// NewFrame creates the Frame for a cube held in the
+// reference orientation.
+func NewFrame() Frame {
+	res := Frame{}
+	for i := 0; i < len(faceNames); i++ {
+		res[faceNames[i]] = faceNames[i]
+	}
+	return res
+}
+
+// ReferenceFrame creates the Frame for a cube which is
+// held such that applying the given rotations would put
+// it in the reference orientation.
+func ReferenceFrame(reference string) (Frame, error) {
+	res := NewFrame()
+	rotations := strings.Fields(reference)
+	for i := len(rotations) - 1; i >= 0; i-- {
+		if err := res.Rotate(InverseMove(rotations[i])); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Rotate updates the frame for a cube rotation, such as
+// "x", "y2", or "z'".
+func (f Frame) Rotate(rotation string) error {
+	rotation = strings.Replace(rotation, "2'", "2", 1)
+	if len(rotation) == 0 || len(rotation) > 2 {
+		return errors.New("invalid rotation: " + rotation)
+	}
+	sources, ok := rotationSources[rotation[0]]
+	if !ok {
+		return errors.New("invalid rotation: " + rotation)
+	}
+	repeats := 1
+	if len(rotation) == 2 {
+		repeats, ok = map[byte]int{'\'': 3, '2': 2}[rotation[1]]
+		if !ok {
+			return errors.New("invalid rotation: " + rotation)
+		}
+	}
+	for i := 0; i < repeats; i++ {
+		old := Frame{}
+		for k, v := range f {
+			old[k] = v
+		}
+		for face, source := range sources {
+			f[face] = old[source]
+		}
+	}
+	return nil
+}
+
+// MapMove converts a face turn of the cube, as it is
+// currently held, into the same turn in the reference
+// orientation.
+func (f Frame) MapMove(move string) (string, error) {
+	if len(move) == 0 {
+		return "", errors.New("empty move is invalid")
+	}
+	face, ok := f[move[0]]
+	if !ok {
+		return "", errors.New("not a face turn: " + move)
+	}
+	return string(face) + move[1:], nil
+}
+
+// CanonicalMoves converts a sequence of moves into face
+// turns in the reference orientation, given the Frame of
+// the cube before the first move.
+// Rotations only update the frame, and wide turns and
+// slice moves are broken into face turns and rotations.
+// The frame is left as it is after the final move.
+func CanonicalMoves(f Frame, moves []string) ([]string, error) {
+	var res []string
+	for _, move := range moves {
+		parts, err := expandMove(move)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range parts {
+			if _, ok := rotationSources[part[0]]; ok {
+				if err := f.Rotate(part); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			mapped, err := f.MapMove(part)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, mapped)
+		}
+	}
+	return res, nil
+}
+
+// CanonicalSample converts a sample so that its starting
+// state is viewed in the reference orientation, which is
+// reached by performing the reference rotations.
+// The moves of the resulting sample are face turns in the
+// reference orientation, with all rotations removed.
+func CanonicalSample(s Sample, reference string) (Sample, error) {
+	frame, err := ReferenceFrame(reference)
+	if err != nil {
+		return Sample{}, err
+	}
+	moves, err := CanonicalMoves(frame, strings.Fields(s.Moves))
+	if err != nil {
+		return Sample{}, err
+	}
+	start := *s.Start
+	for _, rotation := range strings.Fields(reference) {
+		if err := Move(&start, rotation); err != nil {
+			return Sample{}, err
+		}
+	}
+	res := s
+	res.Start = &start
+	res.Moves = strings.Join(moves, " ")
+	return res, nil
+}
+
+// Canonicalize converts every sample in the set with
+// CanonicalSample and replaces the MoveMap with one that
+// contains every face turn.
+// The samples keep the hashes they had before, so that
+// they are split the same way.
+func (s *SampleSet) Canonicalize(reference string) error {
+	for i, sample := range s.Samples {
+		canonical, err := CanonicalSample(sample, reference)
+		if err != nil {
+			return err
+		}
+		canonical.hash = string(s.Hash(i))
+		s.Samples[i] = canonical
+	}
+	s.MoveMap = canonicalMoveMap()
+	return nil
+}
+
+func canonicalMoveMap() map[string]int {
+	var moves []string
+	for i := 0; i < len(faceNames); i++ {
+		for _, suffix := range []string{"", "'", "2"} {
+			moves = append(moves, faceNames[i:i+1]+suffix)
+		}
+	}
+	sort.Strings(moves)
+	res := map[string]int{}
+	for i, move := range moves {
+		res[move] = i
+	}
+	return res
+}
+
+// expandMove breaks a move into face turns and rotations.
+func expandMove(move string) ([]string, error) {
+	move = strings.Replace(move, "2'", "2", 1)
+	if len(move) == 0 {
+		return nil, errors.New("empty move is invalid")
+	}
+	hybrid, ok := hybridMoves[move[:1]]
+	if !ok {
+		return []string{move}, nil
+	}
+	parts := strings.Fields(hybrid)
+	if len(move) == 1 {
+		return parts, nil
+	} else if len(move) > 2 {
+		return nil, errors.New("invalid move: " + move)
+	}
+	for i, part := range parts {
+		switch move[1] {
+		case '\'':
+			parts[i] = InverseMove(part)
+		case '2':
+			parts[i] = part[:1] + "2"
+		default:
+			return nil, errors.New("invalid move: " + move)
+		}
+	}
+	return parts, nil
+}
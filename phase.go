@@ -0,0 +1,106 @@
+package humancube
+
+import "github.com/unixpickle/gocube"
+
+// A Phase is a step of a CFOP solve.
+type Phase int
+
+const (
+	PhaseCross Phase = iota
+	PhaseF2L
+	PhaseOLL
+	PhasePLL
+	PhaseSolved
+)
+
+// NumPhases is the number of distinct Phases.
+const NumPhases = 5
+
+// String returns the name of the phase.
+func (p Phase) String() string {
+	switch p {
+	case PhaseCross:
+		return "cross"
+	case PhaseF2L:
+		return "F2L"
+	case PhaseOLL:
+		return "OLL"
+	case PhasePLL:
+		return "PLL"
+	case PhaseSolved:
+		return "solved"
+	}
+	return "unknown"
+}
+
+// DetectPhase determines which phase of a CFOP solve a
+// cube is in, assuming the cross is on the bottom.
+func DetectPhase(c *gocube.CubieCube) Phase {
+	if c.Solved() {
+		return PhaseSolved
+	} else if !hasCrossSolved(c) {
+		return PhaseCross
+	} else if !hasF2LSolved(c) {
+		return PhaseF2L
+	} else if !hasLastLayerOriented(c) {
+		return PhaseOLL
+	}
+	return PhasePLL
+}
+
+// MaxProgress is the Progress of a solved cube.
+const MaxProgress = 7
+
+// Progress measures how far a cube is through a CFOP
+// solve, assuming the cross is on the bottom.
+// It is 0 before the cross, 1 with the cross solved, 2
+// through 4 as the first three F2L pairs are solved, 5
+// once F2L is done, 6 once the last layer is oriented,
+// and MaxProgress once the cube is solved.
+func Progress(c *gocube.CubieCube) int {
+	switch DetectPhase(c) {
+	case PhaseCross:
+		return 0
+	case PhaseF2L:
+		return 1 + numSolvedPairs(describeF2LPairs(c))
+	case PhaseOLL:
+		return 5
+	case PhasePLL:
+		return 6
+	default:
+		return MaxProgress
+	}
+}
+
+// ProgressName returns a short description of a value
+// returned by Progress.
+func ProgressName(progress int) string {
+	switch {
+	case progress == 0:
+		return "none"
+	case progress == 1:
+		return "cross"
+	case progress >= 2 && progress <= 4:
+		return []string{"pair 1", "pair 2", "pair 3"}[progress-2]
+	case progress == 5:
+		return "F2L"
+	case progress == 6:
+		return "OLL"
+	default:
+		return "solved"
+	}
+}
+
+func hasLastLayerOriented(c *gocube.CubieCube) bool {
+	for _, edgeIdx := range lastLayerEdges {
+		if c.Edges[edgeIdx].Flip {
+			return false
+		}
+	}
+	for _, cornerIdx := range lastLayerCorners {
+		if c.Corners[cornerIdx].Orientation != 1 {
+			return false
+		}
+	}
+	return true
+}
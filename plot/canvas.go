@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// A Canvas is something a plot can be drawn on.
+type Canvas interface {
+	Line(x1, y1, x2, y2 float64, c color.RGBA)
+
+	// Text draws text with its baseline starting at (x, y).
+	Text(x, y float64, s string, c color.RGBA)
+
+	Encode(w io.Writer) error
+}
+
+// PNGCanvas draws into an image.
+type PNGCanvas struct {
+	img *image.RGBA
+}
+
+// NewPNGCanvas creates a white PNGCanvas.
+func NewPNGCanvas(width, height int) *PNGCanvas {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	return &PNGCanvas{img: img}
+}
+
+func (p *PNGCanvas) Line(x1, y1, x2, y2 float64, c color.RGBA) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+	for i := 0; i <= steps; i++ {
+		frac := float64(i) / float64(steps)
+		x := x1 + frac*(x2-x1)
+		y := y1 + frac*(y2-y1)
+		p.img.SetRGBA(int(math.Floor(x+0.5)), int(math.Floor(y+0.5)), c)
+	}
+}
+
+func (p *PNGCanvas) Text(x, y float64, s string, c color.RGBA) {
+	d := &font.Drawer{
+		Dst:  p.img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(int(x), int(y)),
+	}
+	d.DrawString(s)
+}
+
+func (p *PNGCanvas) Encode(w io.Writer) error {
+	return png.Encode(w, p.img)
+}
+
+// SVGCanvas builds an SVG document.
+type SVGCanvas struct {
+	width  int
+	height int
+	body   bytes.Buffer
+}
+
+// NewSVGCanvas creates a white SVGCanvas.
+func NewSVGCanvas(width, height int) *SVGCanvas {
+	res := &SVGCanvas{width: width, height: height}
+	fmt.Fprintf(&res.body, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	return res
+}
+
+func (s *SVGCanvas) Line(x1, y1, x2, y2 float64, c color.RGBA) {
+	fmt.Fprintf(&s.body, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s"/>`+"\n",
+		x1, y1, x2, y2, svgColor(c))
+}
+
+func (s *SVGCanvas) Text(x, y float64, text string, c color.RGBA) {
+	fmt.Fprintf(&s.body, `<text x="%.2f" y="%.2f" fill="%s" font-family="sans-serif" `+
+		`font-size="12">%s</text>`+"\n", x, y, svgColor(c), html.EscapeString(text))
+}
+
+func (s *SVGCanvas) Encode(w io.Writer) error {
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d">`+
+		"\n%s</svg>\n", s.width, s.height, s.body.String())
+	return err
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unixpickle/humancube"
+)
+
+// metricFuncs extracts each plottable value from a row.
+var metricFuncs = map[string]func(r *humancube.MetricsRow) float64{
+	"batch":           func(r *humancube.MetricsRow) float64 { return float64(r.Batch) },
+	"epoch":           func(r *humancube.MetricsRow) float64 { return float64(r.Epoch) },
+	"seconds":         func(r *humancube.MetricsRow) float64 { return r.Seconds },
+	"train_cost":      func(r *humancube.MetricsRow) float64 { return r.TrainCost },
+	"validation_cost": func(r *humancube.MetricsRow) float64 { return r.ValidationCost },
+	"accuracy":        func(r *humancube.MetricsRow) float64 { return r.Accuracy },
+	"step_size":       func(r *humancube.MetricsRow) float64 { return r.StepSize },
+}
+
+var seriesColors = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff},
+	{0xff, 0x7f, 0x0e, 0xff},
+	{0x2c, 0xa0, 0x2c, 0xff},
+	{0xd6, 0x27, 0x28, 0xff},
+	{0x94, 0x67, 0xbd, 0xff},
+	{0x8c, 0x56, 0x4b, 0xff},
+	{0xe3, 0x77, 0xc2, 0xff},
+	{0x7f, 0x7f, 0x7f, 0xff},
+}
+
+type Options struct {
+	Metrics string
+	X       string
+	Width   int
+	Height  int
+	Smooth  int
+}
+
+// A Series is one line of the plot.
+type Series struct {
+	Name   string
+	Points [][2]float64
+}
+
+func main() {
+	var opts Options
+	flag.StringVar(&opts.Metrics, "metrics", "train_cost,validation_cost",
+		"comma-separated metrics to plot")
+	flag.StringVar(&opts.X, "x", "batch", "x-axis metric (batch, epoch, seconds)")
+	flag.IntVar(&opts.Width, "width", 800, "image width")
+	flag.IntVar(&opts.Height, "height", 500, "image height")
+	flag.IntVar(&opts.Smooth, "smooth", 1, "moving average window for each series")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] output.png|output.svg "+
+			"metrics_file ...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if err := Plot(flag.Arg(0), flag.Args()[1:], &opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func Plot(outFile string, metricsFiles []string, opts *Options) error {
+	xFunc, ok := metricFuncs[opts.X]
+	if !ok {
+		return errors.New("unknown x-axis metric: " + opts.X)
+	}
+	var series []*Series
+	for _, path := range metricsFiles {
+		rows, err := humancube.ReadMetricsLog(path)
+		if err != nil {
+			return errors.New("read " + path + ": " + err.Error())
+		}
+		for _, metric := range strings.Split(opts.Metrics, ",") {
+			yFunc, ok := metricFuncs[metric]
+			if !ok {
+				return errors.New("unknown metric: " + metric)
+			}
+			s := &Series{Name: metric}
+			if len(metricsFiles) > 1 {
+				s.Name = filepath.Base(path) + " " + metric
+			}
+			for _, row := range rows {
+				s.Points = append(s.Points, [2]float64{xFunc(row), yFunc(row)})
+			}
+			s.Points = smooth(s.Points, opts.Smooth)
+			series = append(series, s)
+		}
+	}
+
+	var c Canvas
+	switch strings.ToLower(filepath.Ext(outFile)) {
+	case ".png":
+		c = NewPNGCanvas(opts.Width, opts.Height)
+	case ".svg":
+		c = NewSVGCanvas(opts.Width, opts.Height)
+	default:
+		return errors.New("output must be a .png or .svg file")
+	}
+	drawPlot(c, series, opts)
+
+	f, err := os.Create(outFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.Encode(f)
+}
+
+func smooth(points [][2]float64, window int) [][2]float64 {
+	if window <= 1 {
+		return points
+	}
+	res := make([][2]float64, len(points))
+	var sum float64
+	for i, point := range points {
+		sum += point[1]
+		if i >= window {
+			sum -= points[i-window][1]
+		}
+		count := math.Min(float64(i+1), float64(window))
+		res[i] = [2]float64{point[0], sum / count}
+	}
+	return res
+}
+
+func drawPlot(c Canvas, series []*Series, opts *Options) {
+	const (
+		left   = 70
+		right  = 20
+		top    = 20
+		bottom = 40
+	)
+	width, height := float64(opts.Width), float64(opts.Height)
+	black := color.RGBA{0, 0, 0, 0xff}
+	gray := color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+
+	minX, maxX, minY, maxY := bounds(series)
+	toScreen := func(p [2]float64) (float64, float64) {
+		x := left + (p[0]-minX)/(maxX-minX)*(width-left-right)
+		y := height - bottom - (p[1]-minY)/(maxY-minY)*(height-top-bottom)
+		return x, y
+	}
+
+	const ticks = 5
+	for i := 0; i <= ticks; i++ {
+		frac := float64(i) / ticks
+		xVal := minX + frac*(maxX-minX)
+		yVal := minY + frac*(maxY-minY)
+		x, _ := toScreen([2]float64{xVal, minY})
+		_, y := toScreen([2]float64{minX, yVal})
+		c.Line(x, top, x, height-bottom, gray)
+		c.Line(left, y, width-right, y, gray)
+		c.Text(x-10, height-bottom+16, formatTick(xVal), black)
+		c.Text(4, y+4, formatTick(yVal), black)
+	}
+	c.Line(left, height-bottom, width-right, height-bottom, black)
+	c.Line(left, top, left, height-bottom, black)
+	c.Text(width/2, height-6, opts.X, black)
+
+	for i, s := range series {
+		col := seriesColors[i%len(seriesColors)]
+		for j := 1; j < len(s.Points); j++ {
+			x1, y1 := toScreen(s.Points[j-1])
+			x2, y2 := toScreen(s.Points[j])
+			c.Line(x1, y1, x2, y2, col)
+		}
+		legendY := top + 14 + float64(i)*16
+		c.Line(width-right-180, legendY-4, width-right-160, legendY-4, col)
+		c.Text(width-right-154, legendY, s.Name, black)
+	}
+}
+
+func bounds(series []*Series) (minX, maxX, minY, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	for _, s := range series {
+		for _, p := range s.Points {
+			if math.IsNaN(p[1]) || math.IsInf(p[1], 0) {
+				continue
+			}
+			minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+			minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 1, 0, 1
+	}
+	if maxX == minX {
+		maxX = minX + 1
+	}
+	if maxY == minY {
+		maxY = minY + 1
+	}
+	return
+}
+
+func formatTick(x float64) string {
+	return fmt.Sprintf("%.4g", x)
+}
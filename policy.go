@@ -0,0 +1,120 @@
+package humancube
+
+import (
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/weakai/rnn"
+)
+
+// A PolicyState is a partial solve, along with the state
+// the network needs to continue it.
+//
+// Unlike an rnn.Runner, PolicyStates can be branched, so
+// search algorithms can explore many continuations of the
+// same partial solve.
+type PolicyState struct {
+	Cube  gocube.CubieCube
+	Moves []string
+
+	// LogProb is the total log probability the network
+	// assigned to Moves.
+	LogProb float64
+
+	style      Style
+	blockState linalg.Vector
+}
+
+// An Evaluation is the network's output for a
+// PolicyState.
+type Evaluation struct {
+	// Output is the raw output of the network.
+	Output linalg.Vector
+
+	// Policy contains the log probability of every move,
+	// indexed by the network's MoveMap.
+	Policy linalg.Vector
+
+	nextBlockState linalg.Vector
+}
+
+// StartState creates a PolicyState for a cube which has
+// already been passed through StartCube.
+func (n *Network) StartState(c *gocube.CubieCube) *PolicyState {
+	return &PolicyState{
+		Cube:       *c,
+		style:      n.Style,
+		blockState: make(linalg.Vector, n.Block.StateSize()),
+	}
+}
+
+// Evaluate runs the network on a batch of states.
+// If the network has a Mask, it is applied to the
+// policies.
+func (n *Network) Evaluate(states []*PolicyState) []*Evaluation {
+	if len(states) == 0 {
+		return nil
+	}
+	in := &rnn.BlockInput{}
+	for _, state := range states {
+		inVec := inputVector(n.Encoder, &state.Cube, n.MoveMap, &n.Config.InputConfig,
+			state.Moves, state.style)
+		in.Inputs = append(in.Inputs, &autofunc.Variable{Vector: inVec})
+		in.States = append(in.States, &autofunc.Variable{Vector: state.blockState})
+	}
+	out := n.Block.Batch(in)
+	outputs := out.Outputs()
+	nextStates := out.States()
+
+	res := make([]*Evaluation, len(states))
+	var moveNames []string
+	if n.Mask != nil {
+		moveNames = n.MoveNames()
+	}
+	for i, output := range outputs {
+		policy := n.Policy(output)
+		if n.Mask != nil {
+			policy = n.Mask.Apply(states[i], policy, moveNames)
+		}
+		res[i] = &Evaluation{
+			Output:         output,
+			Policy:         policy,
+			nextBlockState: nextStates[i],
+		}
+	}
+	return res
+}
+
+// MoveNames returns the name of every move, indexed by the
+// network's MoveMap.
+func (n *Network) MoveNames() []string {
+	res := make([]string, len(n.MoveMap))
+	for name, idx := range n.MoveMap {
+		res[idx] = name
+	}
+	return res
+}
+
+// Child creates the state which results from making a
+// move after the state was evaluated.
+// The move is given by its name and its index in the
+// network's MoveMap.
+func (p *PolicyState) Child(e *Evaluation, move string, moveIdx int) *PolicyState {
+	res := &PolicyState{
+		Cube:       p.Cube,
+		Moves:      make([]string, len(p.Moves), len(p.Moves)+1),
+		LogProb:    p.LogProb + e.Policy[moveIdx],
+		style:      p.style,
+		blockState: e.nextBlockState,
+	}
+	copy(res.Moves, p.Moves)
+	res.Moves = append(res.Moves, move)
+	Move(&res.Cube, move)
+	return res
+}
+
+// cubeKey returns a compact string which is identical for
+// identical cube states.
+func cubeKey(c *gocube.CubieCube) string {
+	return string(appendPackedCube(nil, c))
+}
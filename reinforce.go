@@ -0,0 +1,236 @@
+package humancube
+
+import (
+	"math"
+
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/sgd"
+	"github.com/unixpickle/weakai/rnn/seqtoseq"
+)
+
+// A Rollout is an attempt by a network to solve a cube,
+// recorded for policy-gradient training.
+type Rollout struct {
+	Moves  []string
+	Solved bool
+
+	// Inputs are the network's inputs at every step.
+	Inputs []linalg.Vector
+
+	// MoveIndices are the moves' indices in the MoveMap.
+	MoveIndices []int
+
+	// LogProbs and RefLogProbs are the log probabilities
+	// of each move under the network and under the
+	// reference network.
+	LogProbs    []float64
+	RefLogProbs []float64
+
+	// Progress is the Progress after each move.
+	Progress []int
+	start    int
+}
+
+// Rollouts runs the network on every cube at once,
+// sampling moves from its policy until each cube is solved
+// or maxMoves moves are made.
+// The reference network, which must use the same MoveMap,
+// scores each move for the KL penalty.
+//
+// Neither network should have a Mask, since the policy
+// gradient is taken through the unmasked policy.
+// The cubes should already have been passed through
+// StartCube.
+func (n *Network) Rollouts(ref *Network, cubes []*gocube.CubieCube,
+	maxMoves int) []*Rollout {
+	moveNames := n.MoveNames()
+	res := make([]*Rollout, len(cubes))
+	var active []int
+	var states, refStates []*PolicyState
+	for i, cube := range cubes {
+		res[i] = &Rollout{start: Progress(cube), Solved: cube.Solved()}
+		if !res[i].Solved && maxMoves > 0 {
+			active = append(active, i)
+			states = append(states, n.StartState(cube))
+			refStates = append(refStates, ref.StartState(cube))
+		}
+	}
+
+	for len(active) > 0 {
+		evals := n.Evaluate(states)
+		refEvals := ref.Evaluate(refStates)
+		var nextActive []int
+		var nextStates, nextRefStates []*PolicyState
+		for i, idx := range active {
+			r := res[idx]
+			state := states[i]
+			moveIdx := DefaultSampler.Sample(evals[i].Policy)
+			move := moveNames[moveIdx]
+
+			r.Inputs = append(r.Inputs, inputVector(n.Encoder, &state.Cube, n.MoveMap,
+				&n.Config.InputConfig, state.Moves, state.style))
+			r.MoveIndices = append(r.MoveIndices, moveIdx)
+			r.LogProbs = append(r.LogProbs, evals[i].Policy[moveIdx])
+			r.RefLogProbs = append(r.RefLogProbs, refEvals[i].Policy[ref.MoveMap[move]])
+
+			child := state.Child(evals[i], move, moveIdx)
+			r.Moves = child.Moves
+			r.Progress = append(r.Progress, Progress(&child.Cube))
+			if child.Cube.Solved() {
+				r.Solved = true
+			} else if len(child.Moves) < maxMoves {
+				nextActive = append(nextActive, idx)
+				nextStates = append(nextStates, child)
+				nextRefStates = append(nextRefStates,
+					refStates[i].Child(refEvals[i], move, ref.MoveMap[move]))
+			}
+		}
+		active = nextActive
+		states = nextStates
+		refStates = nextRefStates
+	}
+	return res
+}
+
+// RewardParams configures the rewards for Rollouts.
+type RewardParams struct {
+	// SolveReward is given for solving the cube.
+	SolveReward float64
+
+	// MilestoneReward is given each time Progress reaches
+	// a new maximum.
+	MilestoneReward float64
+
+	// MovePenalty is subtracted for every move, favoring
+	// shorter solutions.
+	MovePenalty float64
+
+	// KLWeight scales the per-move penalty for the log
+	// probability ratio between the network and the
+	// reference network.
+	KLWeight float64
+
+	// Discount is applied to future rewards.
+	// A value of 0 is treated as 1.
+	Discount float64
+}
+
+// Rewards computes the reward for every move of a
+// rollout, including the KL penalty.
+func (r *RewardParams) Rewards(rollout *Rollout) []float64 {
+	res := make([]float64, len(rollout.MoveIndices))
+	best := rollout.start
+	for i := range res {
+		res[i] = -r.MovePenalty - r.KLWeight*(rollout.LogProbs[i]-rollout.RefLogProbs[i])
+		if rollout.Progress[i] > best {
+			res[i] += r.MilestoneReward * float64(rollout.Progress[i]-best)
+			best = rollout.Progress[i]
+		}
+	}
+	if rollout.Solved && len(res) > 0 {
+		res[len(res)-1] += r.SolveReward
+	}
+	return res
+}
+
+// ReinforceSet is an sgd.SampleSet for REINFORCE.
+//
+// Each sample's expected outputs are one-hot vectors of
+// the chosen moves, scaled by their advantages, so that
+// the policy part of Network.CostFunc(0) is the REINFORCE
+// objective.
+type ReinforceSet struct {
+	Rollouts   []*Rollout
+	Advantages [][]float64
+
+	MoveCount  int
+	OutputSize int
+}
+
+// NewReinforceSet computes the discounted return of every
+// move, and uses the returns, normalized across all of the
+// moves, as advantages.
+func (n *Network) NewReinforceSet(rollouts []*Rollout, r *RewardParams) *ReinforceSet {
+	discount := r.Discount
+	if discount == 0 {
+		discount = 1
+	}
+	res := &ReinforceSet{
+		MoveCount:  len(n.MoveMap),
+		OutputSize: n.Config.OutputConfig.Size(len(n.MoveMap)),
+	}
+	var sum, sqSum, count float64
+	for _, rollout := range rollouts {
+		if len(rollout.MoveIndices) == 0 {
+			continue
+		}
+		rewards := r.Rewards(rollout)
+		returns := make([]float64, len(rewards))
+		var future float64
+		for i := len(rewards) - 1; i >= 0; i-- {
+			future = rewards[i] + discount*future
+			returns[i] = future
+			sum += future
+			sqSum += future * future
+			count++
+		}
+		res.Rollouts = append(res.Rollouts, rollout)
+		res.Advantages = append(res.Advantages, returns)
+	}
+	if count == 0 {
+		return res
+	}
+	mean := sum / count
+	std := math.Sqrt(math.Max(0, sqSum/count-mean*mean))
+	if std == 0 {
+		std = 1
+	}
+	for _, advantages := range res.Advantages {
+		for i, x := range advantages {
+			advantages[i] = (x - mean) / std
+		}
+	}
+	return res
+}
+
+// Len returns the number of rollouts.
+func (r *ReinforceSet) Len() int {
+	return len(r.Rollouts)
+}
+
+// Swap swaps two rollouts.
+func (r *ReinforceSet) Swap(i, j int) {
+	r.Rollouts[i], r.Rollouts[j] = r.Rollouts[j], r.Rollouts[i]
+	r.Advantages[i], r.Advantages[j] = r.Advantages[j], r.Advantages[i]
+}
+
+// GetSample generates a seqtoseq.Sample for a rollout.
+func (r *ReinforceSet) GetSample(idx int) interface{} {
+	rollout := r.Rollouts[idx]
+	outs := make([]linalg.Vector, len(rollout.MoveIndices))
+	for i, moveIdx := range rollout.MoveIndices {
+		outs[i] = make(linalg.Vector, r.OutputSize)
+		outs[i][moveIdx] = r.Advantages[idx][i]
+		if r.OutputSize > r.MoveCount {
+			outs[i][r.MoveCount] = math.NaN()
+		}
+	}
+	return seqtoseq.Sample{Inputs: rollout.Inputs, Outputs: outs}
+}
+
+// Copy returns a copy of the set.
+func (r *ReinforceSet) Copy() sgd.SampleSet {
+	res := *r
+	res.Rollouts = append([]*Rollout{}, r.Rollouts...)
+	res.Advantages = append([][]float64{}, r.Advantages...)
+	return &res
+}
+
+// Subset returns a subset of the set.
+func (r *ReinforceSet) Subset(i, j int) sgd.SampleSet {
+	res := *r
+	res.Rollouts = r.Rollouts[i:j]
+	res.Advantages = r.Advantages[i:j]
+	return &res
+}
@@ -3,62 +3,54 @@ package main
 import (
 	"errors"
 	"fmt"
-	"math"
-	"math/rand"
+	"strings"
 
 	"github.com/unixpickle/humancube"
-	"github.com/unixpickle/num-analysis/linalg"
-	"github.com/unixpickle/weakai/rnn"
 )
 
 const MaxRunLength = 200
 
-func RunCmd(netFile, scramble string) error {
+func RunCmd(netFile, scramble string, opts *RunOptions) error {
 	cube, err := humancube.CubeForMoves(scramble)
 	if err != nil {
 		return errors.New("bad scramble: " + err.Error())
 	}
 
-	net, err := humancube.ReadNetwork(netFile)
+	net, err := readNetwork(netFile, opts)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("Moves:")
-
-	runner := &rnn.Runner{Block: net.Block}
-	for i := 0; i < MaxRunLength; i++ {
-		res := runner.StepTime(humancube.CubeVector(cube))
-		move := randomMove(net, res)
-		fmt.Print(move + " ")
-		humancube.Move(cube, move)
-		if cube.Solved() {
-			fmt.Println()
-			fmt.Println("Cube solved!")
-			return nil
-		}
+	if err := net.StartCube(cube); err != nil {
+		return err
 	}
 
-	fmt.Println()
-	fmt.Println("Cube not solved after", MaxRunLength, "moves.")
+	params, err := opts.Hybrid.Params()
+	if err != nil {
+		return err
+	} else if params == nil {
+		printSolution(net, opts.solve(net, cube))
+		return nil
+	}
 
+	solution := opts.solve(net, cube)
+	hybrid, err := humancube.FinishSolution(cube, solution, params)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Moves:")
+	fmt.Println(strings.Join(net.SolutionMoves(hybrid.Moves()), " "))
+	fmt.Println("Network moves:", len(hybrid.NetworkMoves))
+	fmt.Println("Solver moves:", len(hybrid.SolverMoves))
 	return nil
 }
 
-func randomMove(n *humancube.Network, out linalg.Vector) string {
-	num := rand.Float64()
-	outIdx := 0
-	for outIdx < len(out)-1 && num > 0 {
-		num -= math.Exp(out[outIdx])
-		if num > 0 {
-			outIdx++
-		}
-	}
-
-	for name, idx := range n.MoveMap {
-		if idx == outIdx {
-			return name
-		}
+func printSolution(net *humancube.Network, s *humancube.Solution) {
+	fmt.Println("Moves:")
+	fmt.Println(strings.Join(net.SolutionMoves(s.Moves), " "))
+	if s.Solved {
+		fmt.Printf("Cube solved in %d moves (log probability %f).\n", len(s.Moves), s.LogProb)
+	} else {
+		fmt.Println("Cube not solved after", len(s.Moves), "moves.")
 	}
-	return "?"
 }
@@ -6,36 +6,73 @@ import (
 
 	"github.com/unixpickle/gocube"
 	"github.com/unixpickle/humancube"
-	"github.com/unixpickle/weakai/rnn"
 )
 
 const ScramblePrintInterval = 50
 
-func RunManyCmd(netFile string) error {
-	net, err := humancube.ReadNetwork(netFile)
+func RunManyCmd(netFile string, opts *RunOptions) error {
+	net, err := readNetwork(netFile, opts)
 	if err != nil {
 		return err
 	}
 
-	log.Println("Running on scrambles until one gets solved.")
+	hybrid, err := opts.Hybrid.Params()
+	if err != nil {
+		return err
+	}
+
+	log.Println("Running on scrambles until the network solves one.")
 
+	var stats handOffStats
 	runIdx := 0
-	runner := &rnn.Runner{Block: net.Block}
 	for {
 		cube := gocube.RandomCubieCube()
-		for i := 0; i < MaxRunLength; i++ {
-			res := runner.StepTime(humancube.CubeVector(&cube))
-			move := randomMove(net, res)
-			humancube.Move(&cube, move)
-			if cube.Solved() {
-				fmt.Println("Solved cube after", runIdx, "tries.")
-				return nil
+		if err := net.StartCube(&cube); err != nil {
+			return err
+		}
+		solution := opts.solve(net, &cube)
+		stats.Tries++
+		if solution.Solved {
+			stats.Solved++
+			fmt.Println("Solved cube after", runIdx, "tries.")
+			if hybrid != nil {
+				stats.Print()
+			}
+			return nil
+		} else if hybrid != nil {
+			if h, err := humancube.FinishSolution(&cube, solution, hybrid); err == nil {
+				stats.HandOffs++
+				stats.NetworkMoves += len(h.NetworkMoves)
 			}
 		}
-		runner.Reset()
 		runIdx++
 		if runIdx%ScramblePrintInterval == 0 {
 			log.Println("Made", runIdx, "failed solve attempts.")
+			if hybrid != nil {
+				stats.Print()
+			}
 		}
 	}
 }
+
+// handOffStats records how often the network solved cubes
+// on its own, and how the classical solver finished the
+// rest of them.
+type handOffStats struct {
+	Tries  int
+	Solved int
+
+	// HandOffs counts the cubes finished by the classical
+	// solver, and NetworkMoves the network moves it kept
+	// from them.
+	HandOffs     int
+	NetworkMoves int
+}
+
+func (h *handOffStats) Print() {
+	fmt.Printf("Network solved %d/%d cubes on its own.\n", h.Solved, h.Tries)
+	if h.HandOffs > 0 {
+		fmt.Printf("The classical solver took over %d times, after %.2f network moves "+
+			"on average.\n", h.HandOffs, float64(h.NetworkMoves)/float64(h.HandOffs))
+	}
+}
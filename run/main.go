@@ -1,19 +1,35 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/humancube"
 )
 
+// RunOptions stores the flags which control how the
+// network is run.
+type RunOptions struct {
+	Decoder humancube.DecoderOptions
+	Hybrid  humancube.HybridOptions
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		dieUsage()
-	}
+	var opts RunOptions
+	opts.Decoder.AddFlags(flag.CommandLine, 0)
+	opts.Hybrid.AddFlags(flag.CommandLine)
+	flag.Usage = dieUsage
+	flag.Parse()
+
 	var cmdErr error
-	if len(os.Args) == 3 {
-		cmdErr = RunCmd(os.Args[1], os.Args[2])
-	} else if len(os.Args) == 2 {
-		cmdErr = RunManyCmd(os.Args[1])
+	if err := opts.Validate(); err != nil {
+		cmdErr = err
+	} else if flag.NArg() == 2 {
+		cmdErr = RunCmd(flag.Arg(0), flag.Arg(1), &opts)
+	} else if flag.NArg() == 1 {
+		cmdErr = RunManyCmd(flag.Arg(0), &opts)
 	} else {
 		dieUsage()
 	}
@@ -23,6 +39,31 @@ func main() {
 }
 
 func dieUsage() {
-	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "network_file [scramble]")
+	fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "[flags] network_file [scramble]")
+	flag.PrintDefaults()
 	os.Exit(1)
 }
+
+// Validate checks the flags.
+func (r *RunOptions) Validate() error {
+	if err := r.Decoder.Validate(); err != nil {
+		return err
+	}
+	_, err := r.Hybrid.Params()
+	return err
+}
+
+// solve runs the decoding algorithm selected by the
+// options.
+func (r *RunOptions) solve(net *humancube.Network, cube *gocube.CubieCube) *humancube.Solution {
+	return r.Decoder.Decoder(MaxRunLength).Solve(net, cube)
+}
+
+func readNetwork(path string, opts *RunOptions) (*humancube.Network, error) {
+	net, err := humancube.ReadNetwork(path)
+	if err != nil {
+		return nil, err
+	}
+	opts.Decoder.Apply(net)
+	return net, nil
+}
@@ -2,7 +2,9 @@ package humancube
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"sort"
 	"strings"
 
 	"github.com/unixpickle/gocube"
@@ -15,12 +17,50 @@ import (
 type Sample struct {
 	Start *gocube.CubieCube
 	Moves string
+
+	// Method and Solver describe where the solve came
+	// from, if known.
+	Method string
+	Solver string
+
+	// Origin is empty for human solves, or else it names
+	// the augmentation or search which generated the
+	// sample.
+	Origin string
+
+	// Weight scales the sample's contribution to the cost.
+	// A Weight of 0 is treated as 1.
+	Weight float64
+
+	// hash, if set, is the hash of the sample before it
+	// was canonicalized.
+	hash string
 }
 
+// Style returns the Style of the sample's solve.
+func (s *Sample) Style() Style {
+	return Style{Method: s.Method, Solver: s.Solver}
+}
+
+// DefaultValidationAmount is the fraction of samples which
+// are held out for validation by default.
+const DefaultValidationAmount = 0.1
+
 // A SampleSet is an sgd.SampleSet of Samples.
 type SampleSet struct {
 	Samples []Sample
 	MoveMap map[string]int
+
+	// Encoder is used to generate input vectors.
+	// If it is nil, StickerEncoder is used.
+	Encoder Encoder
+
+	// Inputs specifies the extra inputs to include in each
+	// input vector.
+	Inputs InputConfig
+
+	// Outputs specifies the outputs to predict.
+	Outputs OutputConfig
 }
 
 // NewSampleSet creates a SampleSet with all of the valid
@@ -42,14 +82,36 @@ func NewSampleSet(r []ReconstructedSolve) *SampleSet {
 	}
 	for _, solve := range solves {
 		cube, _ := CubeForMoves(solve.Scramble)
-		res.Samples = append(res.Samples, Sample{
-			Start: cube,
-			Moves: solve.Reconstruction,
-		})
+		sample := Sample{
+			Start:  cube,
+			Moves:  solve.Reconstruction,
+			Method: solve.Method,
+			Solver: solve.Solver,
+		}
+		if solve.Synthetic {
+			sample.Origin = OriginSearch
+		}
+		res.Samples = append(res.Samples, sample)
 	}
 	return res
 }
 
+// UseMoveMap switches the set to another MoveMap, such as
+// the one of the network it is used with.
+// It fails if a sample makes a move which is not in the
+// new MoveMap.
+func (s *SampleSet) UseMoveMap(moveMap map[string]int) error {
+	for _, sample := range s.Samples {
+		for _, move := range strings.Fields(sample.Moves) {
+			if _, ok := moveMap[move]; !ok {
+				return errors.New("move not in move map: " + move)
+			}
+		}
+	}
+	s.MoveMap = moveMap
+	return nil
+}
+
 // LoadSampleSet is like NewSampleSet, but it loads the
 // reconstructions from a file.
 func LoadSampleSet(f string) (*SampleSet, error) {
@@ -79,23 +141,34 @@ func (s *SampleSet) Swap(i, j int) {
 func (s *SampleSet) GetSample(idx int) interface{} {
 	sample := s.Samples[idx]
 	cube := *sample.Start
-
-	var ins, outs []linalg.Vector
-	for _, move := range strings.Fields(sample.Moves) {
-		outputVec := make(linalg.Vector, len(s.MoveMap))
-		outputVec[s.MoveMap[move]] = 1
-		inputVec := CubeVector(&cube)
+	moves := strings.Fields(sample.Moves)
+
+	var ins []linalg.Vector
+	states := []gocube.CubieCube{cube}
+	moveIndices := make([]int, len(moves))
+	for i, move := range moves {
+		moveIndices[i] = s.MoveMap[move]
+		inputVec := inputVector(s.Encoder, &cube, s.MoveMap, &s.Inputs, moves[:i],
+			sample.Style())
 		Move(&cube, move)
 		ins = append(ins, inputVec)
-		outs = append(outs, outputVec)
+		states = append(states, cube)
 	}
+	outs := s.Outputs.targets(states, moveIndices, len(s.MoveMap), sample.weight())
 
 	return seqtoseq.Sample{Inputs: ins, Outputs: outs}
 }
 
 // Hash generates a hash for a sample.
+// The hash does not depend on the encoder, the extra
+// inputs and outputs, or canonicalization, so
+// sgd.HashSplit splits samples the same way for every
+// kind of network.
 func (s *SampleSet) Hash(idx int) []byte {
-	return s.GetSample(idx).(seqtoseq.Sample).Hash()
+	if hash := s.Samples[idx].hash; hash != "" {
+		return []byte(hash)
+	}
+	return sampleHash(s.Samples[idx], s.MoveMap)
 }
 
 // Subset returns a subset of the sample set.
@@ -103,6 +176,9 @@ func (s *SampleSet) Subset(i, j int) sgd.SampleSet {
 	return &SampleSet{
 		Samples: s.Samples[i:j],
 		MoveMap: s.MoveMap,
+		Encoder: s.Encoder,
+		Inputs:  s.Inputs,
+		Outputs: s.Outputs,
 	}
 }
 
@@ -111,9 +187,33 @@ func (s *SampleSet) Copy() sgd.SampleSet {
 	return &SampleSet{
 		Samples: append([]Sample{}, s.Samples...),
 		MoveMap: s.MoveMap,
+		Encoder: s.Encoder,
+		Inputs:  s.Inputs,
+		Outputs: s.Outputs,
 	}
 }
 
+// Solvers returns the sorted names of every known solver
+// in the set.
+func (s *SampleSet) Solvers() []string {
+	seen := map[string]bool{}
+	var res []string
+	for _, sample := range s.Samples {
+		if sample.Solver != "" && !seen[sample.Solver] {
+			seen[sample.Solver] = true
+			res = append(res, sample.Solver)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func sampleHash(sample Sample, moveMap map[string]int) []byte {
+	sample.Weight = 0
+	plain := &SampleSet{Samples: []Sample{sample}, MoveMap: moveMap}
+	return plain.GetSample(0).(seqtoseq.Sample).Hash()
+}
+
 func usableSolves(solves []ReconstructedSolve) []ReconstructedSolve {
 	var res []ReconstructedSolve
 
@@ -0,0 +1,509 @@
+package humancube
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/sgd"
+	"github.com/unixpickle/weakai/rnn/seqtoseq"
+)
+
+const (
+	storeManifestName = "manifest.json"
+	packedCubeSize    = 20
+
+	// DefaultShardSize is the default number of samples
+	// in each shard of a SampleStore.
+	DefaultShardSize = 2048
+
+	// DefaultShardCache is the default number of shards
+	// a SampleStore keeps in memory.
+	DefaultShardCache = 16
+)
+
+// A SampleStore is a directory of sample shards.
+//
+// Each shard stores the cube state before every move of
+// every sample, along with the index of each move in the
+// store's MoveMap, so samples can be vectorized without
+// re-simulating their solves.
+type SampleStore struct {
+	Dir        string
+	MoveMap    map[string]int
+	ShardSizes []int
+
+	// Key identifies where the samples came from, so that
+	// a store can be reused instead of being rewritten.
+	Key string
+
+	moveNames  []string
+	cacheSize  int
+	cacheLock  sync.Mutex
+	cache      map[int]*cachedShard
+	cacheClock int
+}
+
+type storeManifest struct {
+	MoveMap    map[string]int
+	ShardSizes []int
+	Key        string
+}
+
+type storedSample struct {
+	States []byte
+	Moves  []uint16
+	Method string
+	Solver string
+	Origin string
+	Weight float64
+}
+
+// A cachedShard is a shard which is in the cache or is
+// being loaded into it.
+// The loaded channel is closed once samples or err is set.
+type cachedShard struct {
+	samples  []storedSample
+	err      error
+	loaded   chan struct{}
+	lastUsed int
+}
+
+// A SampleStoreWriter writes samples to a new SampleStore
+// one shard at a time, so that the full set of samples
+// never has to be held in memory.
+type SampleStoreWriter struct {
+	// Key is saved in the store's manifest.
+	Key string
+
+	dir        string
+	moveMap    map[string]int
+	shardSize  int
+	shardSizes []int
+	current    []storedSample
+}
+
+// NewSampleStoreWriter creates a SampleStoreWriter which
+// writes to the given directory, creating it if needed.
+// Any existing store in the directory is invalidated, since
+// its shards will be overwritten.
+func NewSampleStoreWriter(dir string, moveMap map[string]int,
+	shardSize int) (*SampleStoreWriter, error) {
+	if shardSize <= 0 {
+		return nil, errors.New("shard size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	err := os.Remove(filepath.Join(dir, storeManifestName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return &SampleStoreWriter{
+		dir:       dir,
+		moveMap:   moveMap,
+		shardSize: shardSize,
+	}, nil
+}
+
+// Add simulates a sample and adds it to the store.
+func (w *SampleStoreWriter) Add(s Sample) error {
+	moves := strings.Fields(s.Moves)
+	stored := storedSample{
+		States: make([]byte, 0, packedCubeSize*(len(moves)+1)),
+		Moves:  make([]uint16, len(moves)),
+		Method: s.Method,
+		Solver: s.Solver,
+		Origin: s.Origin,
+		Weight: s.Weight,
+	}
+	cube := *s.Start
+	stored.States = appendPackedCube(stored.States, &cube)
+	for i, move := range moves {
+		idx, ok := w.moveMap[move]
+		if !ok {
+			return errors.New("move not in move map: " + move)
+		}
+		stored.Moves[i] = uint16(idx)
+		if err := Move(&cube, move); err != nil {
+			return err
+		}
+		stored.States = appendPackedCube(stored.States, &cube)
+	}
+	w.current = append(w.current, stored)
+	if len(w.current) == w.shardSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes the last shard and the store's manifest,
+// then opens the resulting store.
+func (w *SampleStoreWriter) Close(cacheSize int) (*SampleStore, error) {
+	if len(w.current) > 0 {
+		if err := w.flush(); err != nil {
+			return nil, err
+		}
+	}
+	manifest := storeManifest{MoveMap: w.moveMap, ShardSizes: w.shardSizes, Key: w.Key}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(w.dir, storeManifestName), data, 0644); err != nil {
+		return nil, err
+	}
+	return OpenSampleStore(w.dir, cacheSize)
+}
+
+func (w *SampleStoreWriter) flush() error {
+	path := shardPath(w.dir, len(w.shardSizes))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := gob.NewEncoder(f).Encode(w.current); err != nil {
+		return err
+	}
+	w.shardSizes = append(w.shardSizes, len(w.current))
+	w.current = nil
+	return nil
+}
+
+// WriteSampleStore writes every sample in a SampleSet to
+// a new SampleStore.
+func WriteSampleStore(dir string, s *SampleSet, shardSize, cacheSize int) (*SampleStore, error) {
+	w, err := NewSampleStoreWriter(dir, s.MoveMap, shardSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, sample := range s.Samples {
+		if err := w.Add(sample); err != nil {
+			return nil, err
+		}
+	}
+	return w.Close(cacheSize)
+}
+
+// OpenSampleStore opens a SampleStore which keeps at most
+// cacheSize shards in memory at once.
+func OpenSampleStore(dir string, cacheSize int) (*SampleStore, error) {
+	if cacheSize <= 0 {
+		return nil, errors.New("cache size must be positive")
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, storeManifestName))
+	if err != nil {
+		return nil, err
+	}
+	var manifest storeManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.New("read manifest: " + err.Error())
+	}
+	moveNames := make([]string, len(manifest.MoveMap))
+	for name, idx := range manifest.MoveMap {
+		moveNames[idx] = name
+	}
+	return &SampleStore{
+		Dir:        dir,
+		MoveMap:    manifest.MoveMap,
+		ShardSizes: manifest.ShardSizes,
+		Key:        manifest.Key,
+		moveNames:  moveNames,
+		cacheSize:  cacheSize,
+		cache:      map[int]*cachedShard{},
+	}, nil
+}
+
+// Len returns the total number of samples in the store.
+func (s *SampleStore) Len() int {
+	var res int
+	for _, size := range s.ShardSizes {
+		res += size
+	}
+	return res
+}
+
+// SampleSet creates an sgd.SampleSet with every sample
+// in the store.
+func (s *SampleStore) SampleSet() *StoreSampleSet {
+	indices := make([]int, s.Len())
+	for i := range indices {
+		indices[i] = i
+	}
+	return &StoreSampleSet{Store: s, Indices: indices}
+}
+
+// Sample decodes the sample at the given index.
+func (s *SampleStore) Sample(idx int) (Sample, error) {
+	stored, err := s.storedSample(idx)
+	if err != nil {
+		return Sample{}, err
+	}
+	moves := make([]string, len(stored.Moves))
+	for i, m := range stored.Moves {
+		moves[i] = s.moveNames[m]
+	}
+	start := unpackCube(stored.States)
+	return Sample{
+		Start:  &start,
+		Moves:  strings.Join(moves, " "),
+		Method: stored.Method,
+		Solver: stored.Solver,
+		Origin: stored.Origin,
+		Weight: stored.Weight,
+	}, nil
+}
+
+func (s *SampleStore) storedSample(idx int) (*storedSample, error) {
+	shardIdx, offset, ok := s.locate(idx)
+	if !ok {
+		return nil, errors.New("sample index out of bounds")
+	}
+	shard, err := s.shard(shardIdx)
+	if err != nil {
+		return nil, err
+	}
+	return &shard[offset], nil
+}
+
+// locate finds the shard containing a sample and the
+// sample's index within the shard.
+func (s *SampleStore) locate(idx int) (shardIdx, offset int, ok bool) {
+	if idx < 0 {
+		return 0, 0, false
+	}
+	for shardIdx < len(s.ShardSizes) && idx >= s.ShardSizes[shardIdx] {
+		idx -= s.ShardSizes[shardIdx]
+		shardIdx++
+	}
+	return shardIdx, idx, shardIdx < len(s.ShardSizes)
+}
+
+// shard returns the samples in a shard, reading it from
+// disk if it is not cached.
+// Shards are read without holding the cache lock, so
+// different shards can be read concurrently, and readers
+// of a shard which is being read wait for that read.
+func (s *SampleStore) shard(idx int) ([]storedSample, error) {
+	s.cacheLock.Lock()
+	s.cacheClock++
+	if cached, ok := s.cache[idx]; ok {
+		cached.lastUsed = s.cacheClock
+		s.cacheLock.Unlock()
+		<-cached.loaded
+		return cached.samples, cached.err
+	}
+	if len(s.cache) >= s.cacheSize {
+		oldest := -1
+		for i, cached := range s.cache {
+			if oldest < 0 || cached.lastUsed < s.cache[oldest].lastUsed {
+				oldest = i
+			}
+		}
+		delete(s.cache, oldest)
+	}
+	entry := &cachedShard{loaded: make(chan struct{}), lastUsed: s.cacheClock}
+	s.cache[idx] = entry
+	s.cacheLock.Unlock()
+
+	entry.samples, entry.err = readShard(shardPath(s.Dir, idx))
+	if entry.err != nil {
+		entry.err = fmt.Errorf("read shard %d: %s", idx, entry.err)
+		s.cacheLock.Lock()
+		if s.cache[idx] == entry {
+			delete(s.cache, idx)
+		}
+		s.cacheLock.Unlock()
+	}
+	close(entry.loaded)
+	return entry.samples, entry.err
+}
+
+func readShard(path string) ([]storedSample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var samples []storedSample
+	if err := gob.NewDecoder(f).Decode(&samples); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+// A StoreSampleSet is an sgd.SampleSet which streams
+// samples from a SampleStore.
+type StoreSampleSet struct {
+	Store   *SampleStore
+	Indices []int
+
+	// Encoder is used to generate input vectors.
+	// If it is nil, StickerEncoder is used.
+	Encoder Encoder
+
+	// Inputs specifies the extra inputs to include in each
+	// input vector.
+	Inputs InputConfig
+
+	// Outputs specifies the outputs to predict.
+	Outputs OutputConfig
+}
+
+// Len returns the number of samples.
+func (s *StoreSampleSet) Len() int {
+	return len(s.Indices)
+}
+
+// Swap swaps two samples.
+func (s *StoreSampleSet) Swap(i, j int) {
+	s.Indices[i], s.Indices[j] = s.Indices[j], s.Indices[i]
+}
+
+// Shuffle shuffles the samples while keeping reads from
+// disk rare, which sgd.ShuffleSampleSet does not.
+// The shards are put in a random order and split into
+// windows of half the cache size, then the samples within
+// each window are shuffled.
+func (s *StoreSampleSet) Shuffle() {
+	byShard := map[int][]int{}
+	var shards []int
+	for _, idx := range s.Indices {
+		shardIdx, _, _ := s.Store.locate(idx)
+		if _, ok := byShard[shardIdx]; !ok {
+			shards = append(shards, shardIdx)
+		}
+		byShard[shardIdx] = append(byShard[shardIdx], idx)
+	}
+	sort.Ints(shards)
+
+	window := s.Store.cacheSize / 2
+	if window < 1 {
+		window = 1
+	}
+	order := rand.Perm(len(shards))
+	res := s.Indices[:0]
+	for start := 0; start < len(order); start += window {
+		end := start + window
+		if end > len(order) {
+			end = len(order)
+		}
+		var windowIndices []int
+		for _, i := range order[start:end] {
+			windowIndices = append(windowIndices, byShard[shards[i]]...)
+		}
+		for _, i := range rand.Perm(len(windowIndices)) {
+			res = append(res, windowIndices[i])
+		}
+	}
+}
+
+// GetSample generates a seqtoseq.Sample for the given
+// sample index.
+// It panics if the sample cannot be read from disk.
+func (s *StoreSampleSet) GetSample(idx int) interface{} {
+	stored, err := s.Store.storedSample(s.Indices[idx])
+	if err != nil {
+		panic(err)
+	}
+
+	moves := make([]string, len(stored.Moves))
+	for i, move := range stored.Moves {
+		moves[i] = s.Store.moveNames[move]
+	}
+
+	style := Style{Method: stored.Method, Solver: stored.Solver}
+	var ins []linalg.Vector
+	states := make([]gocube.CubieCube, len(stored.Moves)+1)
+	moveIndices := make([]int, len(stored.Moves))
+	for i := range states {
+		states[i] = unpackCube(stored.States[i*packedCubeSize:])
+	}
+	for i, move := range stored.Moves {
+		moveIndices[i] = int(move)
+		ins = append(ins, inputVector(s.Encoder, &states[i], s.Store.MoveMap, &s.Inputs,
+			moves[:i], style))
+	}
+	outs := s.Outputs.targets(states, moveIndices, len(s.Store.MoveMap),
+		sampleWeight(stored.Weight))
+
+	return seqtoseq.Sample{Inputs: ins, Outputs: outs}
+}
+
+// Hash generates a hash for a sample, just like
+// SampleSet.Hash.
+// It panics if the sample cannot be read from disk.
+func (s *StoreSampleSet) Hash(idx int) []byte {
+	sample, err := s.Store.Sample(s.Indices[idx])
+	if err != nil {
+		panic(err)
+	}
+	return sampleHash(sample, s.Store.MoveMap)
+}
+
+// Subset returns a subset of the sample set.
+func (s *StoreSampleSet) Subset(i, j int) sgd.SampleSet {
+	return &StoreSampleSet{
+		Store:   s.Store,
+		Indices: s.Indices[i:j],
+		Encoder: s.Encoder,
+		Inputs:  s.Inputs,
+		Outputs: s.Outputs,
+	}
+}
+
+// Copy returns a copy of the sample set.
+func (s *StoreSampleSet) Copy() sgd.SampleSet {
+	return &StoreSampleSet{
+		Store:   s.Store,
+		Indices: append([]int{}, s.Indices...),
+		Encoder: s.Encoder,
+		Inputs:  s.Inputs,
+		Outputs: s.Outputs,
+	}
+}
+
+func shardPath(dir string, idx int) string {
+	return filepath.Join(dir, fmt.Sprintf("shard%d.gob", idx))
+}
+
+// appendPackedCube packs each corner and edge of a cube
+// into a single byte.
+func appendPackedCube(b []byte, c *gocube.CubieCube) []byte {
+	for _, corner := range c.Corners {
+		b = append(b, byte(corner.Piece*3+corner.Orientation))
+	}
+	for _, edge := range c.Edges {
+		packed := byte(edge.Piece * 2)
+		if edge.Flip {
+			packed++
+		}
+		b = append(b, packed)
+	}
+	return b
+}
+
+func unpackCube(b []byte) gocube.CubieCube {
+	var res gocube.CubieCube
+	for i := range res.Corners {
+		res.Corners[i].Piece = int(b[i] / 3)
+		res.Corners[i].Orientation = int(b[i] % 3)
+	}
+	for i := range res.Edges {
+		packed := b[len(res.Corners)+i]
+		res.Edges[i].Piece = int(packed / 2)
+		res.Edges[i].Flip = packed%2 == 1
+	}
+	return res
+}
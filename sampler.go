@@ -0,0 +1,140 @@
+package humancube
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/num-analysis/linalg"
+)
+
+// A Sampler picks moves from a network's policy.
+type Sampler struct {
+	// Temperature divides the log probabilities before
+	// sampling.
+	// A temperature of 0 always picks the likeliest move.
+	Temperature float64
+
+	// TopK, if non-zero, restricts sampling to the TopK
+	// likeliest moves.
+	TopK int
+
+	// TopP, if non-zero, restricts sampling to the
+	// smallest set of likeliest moves whose total
+	// probability is at least TopP.
+	TopP float64
+}
+
+// DefaultSampler samples directly from the policy.
+var DefaultSampler = Sampler{Temperature: 1}
+
+// Validate checks that the sampler's settings are usable.
+func (s *Sampler) Validate() error {
+	if s.Temperature < 0 {
+		return errors.New("temperature must not be negative")
+	}
+	if s.TopK < 0 {
+		return errors.New("top-k must not be negative")
+	}
+	if s.TopP < 0 || s.TopP > 1 {
+		return errors.New("top-p must be between 0 and 1")
+	}
+	return nil
+}
+
+type sampleOption struct {
+	idx  int
+	prob float64
+}
+
+// Sample picks the index of a move, given the policy's
+// log probabilities.
+// Moves with a log probability of -Inf, such as those
+// removed by a MoveMask, are never picked.
+func (s *Sampler) Sample(policy linalg.Vector) int {
+	var options []sampleOption
+	for i, logProb := range policy {
+		if !math.IsInf(logProb, -1) {
+			options = append(options, sampleOption{idx: i, prob: logProb})
+		}
+	}
+	if len(options) == 0 {
+		for i, logProb := range policy {
+			options = append(options, sampleOption{idx: i, prob: logProb})
+		}
+	}
+	sort.Sort(sampleOptions(options))
+
+	if s.Temperature == 0 {
+		return options[0].idx
+	}
+	if s.TopK > 0 && len(options) > s.TopK {
+		options = options[:s.TopK]
+	}
+
+	maxLogProb := options[0].prob
+	var total float64
+	for i, option := range options {
+		options[i].prob = math.Exp((option.prob - maxLogProb) / s.Temperature)
+		total += options[i].prob
+	}
+	if s.TopP > 0 {
+		var cumulative float64
+		for i, option := range options {
+			cumulative += option.prob / total
+			if cumulative >= s.TopP {
+				options = options[:i+1]
+				break
+			}
+		}
+		total = 0
+		for _, option := range options {
+			total += option.prob
+		}
+	}
+
+	num := rand.Float64() * total
+	for _, option := range options {
+		num -= option.prob
+		if num <= 0 {
+			return option.idx
+		}
+	}
+	return options[len(options)-1].idx
+}
+
+// SampleSolve solves a cube by repeatedly sampling moves
+// until the cube is solved or maxMoves moves are made.
+//
+// The cube should already have been passed through
+// StartCube.
+func (n *Network) SampleSolve(c *gocube.CubieCube, s *Sampler, maxMoves int) *Solution {
+	moveNames := n.MoveNames()
+	state := n.StartState(c)
+	for len(state.Moves) < maxMoves && !state.Cube.Solved() {
+		eval := n.Evaluate([]*PolicyState{state})[0]
+		moveIdx := s.Sample(eval.Policy)
+		state = state.Child(eval, moveNames[moveIdx], moveIdx)
+	}
+	return &Solution{
+		Moves:   state.Moves,
+		LogProb: state.LogProb,
+		Solved:  state.Cube.Solved(),
+	}
+}
+
+type sampleOptions []sampleOption
+
+func (s sampleOptions) Len() int {
+	return len(s)
+}
+
+func (s sampleOptions) Less(i, j int) bool {
+	return s[i].prob > s[j].prob
+}
+
+func (s sampleOptions) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
@@ -24,6 +24,15 @@ type ReconstructedSolve struct {
 	Scramble       string
 	Reconstruction string
 	Commented      string
+
+	// Solver and Method are empty if the solve's page
+	// does not list them.
+	Solver string
+	Method string
+
+	// Synthetic is set if the solve was found by search
+	// instead of a human (see Network.SearchSolve).
+	Synthetic bool `json:",omitempty"`
 }
 
 func FetchReconstructions() (<-chan ReconstructedSolve, <-chan error) {
@@ -133,9 +142,42 @@ func fetchReconstruction(id int) (*ReconstructedSolve, error) {
 	res.Reconstruction = rawAlgText(algWells[1])
 	res.Commented = commentedAlgText(algWells[1])
 
+	details := solveDetails(parsed)
+	res.Solver = details["solver"]
+	res.Method = details["method"]
+
 	return &res, nil
 }
 
+// solveDetails finds labeled values on a solve page,
+// such as a <dt>Method</dt> followed by <dd>CFOP</dd>.
+// The resulting map is keyed by lowercase labels.
+func solveDetails(n *html.Node) map[string]string {
+	res := map[string]string{}
+	labels := scrape.FindAll(n, func(n *html.Node) bool {
+		switch n.DataAtom {
+		case atom.Dt, atom.Th, atom.Strong:
+			return true
+		}
+		return false
+	})
+	for _, label := range labels {
+		value := label.NextSibling
+		for value != nil && value.Type != html.ElementNode {
+			value = value.NextSibling
+		}
+		if value == nil {
+			continue
+		}
+		key := strings.TrimSuffix(strings.TrimSpace(scrape.Text(label)), ":")
+		key = strings.ToLower(key)
+		if _, ok := res[key]; !ok {
+			res[key] = strings.TrimSpace(scrape.Text(value))
+		}
+	}
+	return res
+}
+
 func rawAlgText(n *html.Node) string {
 	var res string
 	child := n.FirstChild
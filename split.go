@@ -0,0 +1,68 @@
+package humancube
+
+import (
+	"errors"
+	"math/rand"
+
+	"github.com/unixpickle/sgd"
+)
+
+// These are the ways to split off validation samples.
+//
+// HashSplit assigns samples by hash, so a sample stays on
+// the same side of the split as the data grows.
+// RandomSplit shuffles the samples with the split seed.
+const (
+	HashSplit   = "hash"
+	RandomSplit = "random"
+)
+
+// SplitOptions describes how a network's samples were
+// split into training and validation samples.
+type SplitOptions struct {
+	Strategy   string
+	Validation float64
+
+	// Seed seeds RandomSplit.
+	Seed int64
+}
+
+// DefaultSplitOptions returns the split which networks
+// saved without SplitOptions were trained with.
+func DefaultSplitOptions() *SplitOptions {
+	return &SplitOptions{Strategy: HashSplit, Validation: DefaultValidationAmount}
+}
+
+// Validate checks that the options are usable.
+func (s *SplitOptions) Validate() error {
+	switch s.Strategy {
+	case HashSplit, RandomSplit:
+	default:
+		return errors.New("unknown split strategy: " + s.Strategy)
+	}
+	if s.Validation <= 0 || s.Validation >= 1 {
+		return errors.New("validation fraction must be between 0 and 1")
+	}
+	return nil
+}
+
+// Split splits off validation samples.
+// Synthetic samples are never held out, so they all end
+// up in the training samples.
+// RandomSplit reseeds math/rand.
+func (s *SplitOptions) Split(set *SampleSet) (validation, training *SampleSet) {
+	human, synthetic := set.SplitSynthetic()
+	if s.Strategy == RandomSplit {
+		rand.Seed(s.Seed)
+		sgd.ShuffleSampleSet(human)
+		count := int(float64(human.Len()) * s.Validation)
+		validation = human.Subset(0, count).(*SampleSet)
+		training = human.Subset(count, human.Len()).Copy().(*SampleSet)
+	} else {
+		v, t := sgd.HashSplit(human, s.Validation)
+		validation = v.(*SampleSet)
+		training = t.Copy().(*SampleSet)
+	}
+	training.Samples = append(training.Samples, synthetic.Samples...)
+	return
+}
@@ -28,6 +28,7 @@ func main() {
 	}
 
 	var totalEntries int
+	var syntheticEntries int
 	var parsedScrambles int
 	var parsedReconstructions int
 	var correctReconstructions int
@@ -35,6 +36,9 @@ func main() {
 SolveLoop:
 	for _, solve := range solves {
 		totalEntries++
+		if solve.Synthetic {
+			syntheticEntries++
+		}
 		cube, err := humancube.CubeForMoves(solve.Scramble)
 		if err != nil {
 			continue
@@ -52,6 +56,7 @@ SolveLoop:
 	}
 
 	fmt.Println("Processed", totalEntries, "data items:")
+	fmt.Println("  Synthetic items:", syntheticEntries)
 	fmt.Println("  Valid scrambles:", parsedScrambles)
 	fmt.Println("  Valid solutions:", parsedReconstructions)
 	fmt.Println("Correct solutions:", correctReconstructions)
@@ -0,0 +1,78 @@
+package humancube
+
+import (
+	"math/rand"
+	"strings"
+)
+
+// Synthetic checks if the sample was solved by search
+// rather than by a human.
+func (s *Sample) Synthetic() bool {
+	return s.Origin == OriginSearch
+}
+
+// SplitSynthetic splits a sample set into its human and
+// synthetic samples.
+func (s *SampleSet) SplitSynthetic() (human, synthetic *SampleSet) {
+	human = &SampleSet{MoveMap: s.MoveMap, Encoder: s.Encoder, Inputs: s.Inputs,
+		Outputs: s.Outputs}
+	synthetic = &SampleSet{MoveMap: s.MoveMap, Encoder: s.Encoder, Inputs: s.Inputs,
+		Outputs: s.Outputs}
+	for _, sample := range s.Samples {
+		if sample.Synthetic() {
+			synthetic.Samples = append(synthetic.Samples, sample)
+		} else {
+			human.Samples = append(human.Samples, sample)
+		}
+	}
+	return
+}
+
+// RandomScramble generates a scramble of random face
+// turns, never turning the same face twice in a row.
+func RandomScramble(length int) string {
+	moves := make([]string, 0, length)
+	for len(moves) < length {
+		face := rand.Intn(len(faceNames))
+		move := faceNames[face:face+1] + []string{"", "'", "2"}[rand.Intn(3)]
+		if len(moves) > 0 && sameFace(moves[len(moves)-1], move) {
+			continue
+		}
+		moves = append(moves, move)
+	}
+	return strings.Join(moves, " ")
+}
+
+// SearchSolve uses a decoder to solve a scramble and
+// converts the solution into a synthetic
+// ReconstructedSolve in the network's Style.
+// It returns false if no solution was found.
+func (n *Network) SearchSolve(scramble string, d *Decoder) (ReconstructedSolve, bool) {
+	cube, err := CubeForMoves(scramble)
+	if err != nil {
+		return ReconstructedSolve{}, false
+	}
+	if err := n.StartCube(cube); err != nil {
+		return ReconstructedSolve{}, false
+	}
+	solution := d.Solve(n, cube)
+	if !solution.Solved {
+		return ReconstructedSolve{}, false
+	}
+	moves := n.SolutionMoves(solution.Moves)
+	if !solvesScramble(scramble, moves) {
+		return ReconstructedSolve{}, false
+	}
+	return ReconstructedSolve{
+		Scramble:       scramble,
+		Reconstruction: strings.Join(moves, " "),
+		Method:         n.Style.Method,
+		Solver:         n.Style.Solver,
+		Synthetic:      true,
+	}, true
+}
+
+func solvesScramble(scramble string, moves []string) bool {
+	cube, err := CubeForMoves(scramble + " " + strings.Join(moves, " "))
+	return err == nil && cube.Solved()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/sgd"
+)
+
+const (
+	defaultDecayRate1 = 0.9
+	defaultDecayRate2 = 0.999
+	defaultDamping    = 1e-8
+)
+
+// AdamState is the part of Adam which changes during
+// training, stored so that training can be resumed.
+type AdamState struct {
+	Iteration int
+
+	// FirstMoment and SecondMoment are indexed like the
+	// parameters of the Adam.
+	FirstMoment  [][]float64
+	SecondMoment [][]float64
+}
+
+// Adam is like sgd.Adam, but its state can be saved in a
+// checkpoint and restored.
+type Adam struct {
+	Gradienter sgd.Gradienter
+	Params     []*autofunc.Variable
+
+	DecayRate1 float64
+	DecayRate2 float64
+	Damping    float64
+
+	State AdamState
+}
+
+// Gradient computes the underlying gradient and replaces
+// it with the Adam update direction.
+func (a *Adam) Gradient(s sgd.SampleSet) autofunc.Gradient {
+	grad := a.Gradienter.Gradient(s)
+	if a.State.FirstMoment == nil {
+		for _, param := range a.Params {
+			a.State.FirstMoment = append(a.State.FirstMoment, make([]float64, len(param.Vector)))
+			a.State.SecondMoment = append(a.State.SecondMoment, make([]float64, len(param.Vector)))
+		}
+	}
+
+	decay1 := valueOrDefault(a.DecayRate1, defaultDecayRate1)
+	decay2 := valueOrDefault(a.DecayRate2, defaultDecayRate2)
+	damping := valueOrDefault(a.Damping, defaultDamping)
+
+	a.State.Iteration++
+	correction1 := 1 - math.Pow(decay1, float64(a.State.Iteration))
+	correction2 := 1 - math.Pow(decay2, float64(a.State.Iteration))
+	for i, param := range a.Params {
+		paramGrad, ok := grad[param]
+		if !ok {
+			continue
+		}
+		first := a.State.FirstMoment[i]
+		second := a.State.SecondMoment[i]
+		for j, g := range paramGrad {
+			first[j] = decay1*first[j] + (1-decay1)*g
+			second[j] = decay2*second[j] + (1-decay2)*g*g
+			paramGrad[j] = (first[j] / correction1) /
+				(math.Sqrt(second[j]/correction2) + damping)
+		}
+	}
+	return grad
+}
+
+func valueOrDefault(value, def float64) float64 {
+	if value == 0 {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/humancube"
+)
+
+const (
+	checkpointPrefix = "checkpoint-"
+	checkpointExt    = ".gob"
+	bestCheckpoint   = "best" + checkpointExt
+)
+
+type CheckpointOptions struct {
+	// Dir is where checkpoints are stored.
+	// If it is empty, the network file with
+	// ".checkpoints" appended is used.
+	Dir string `json:"dir" yaml:"dir"`
+
+	// Batches and Minutes are how often to save
+	// checkpoints.
+	// Either one may be 0 to disable it.
+	Batches int     `json:"batches" yaml:"batches"`
+	Minutes float64 `json:"minutes" yaml:"minutes"`
+
+	// Keep is the number of recent checkpoints to keep.
+	Keep int `json:"keep" yaml:"keep"`
+
+	// ValidationSize is the number of validation samples
+	// used to pick the best checkpoint.
+	ValidationSize int `json:"validation_size" yaml:"validation_size"`
+
+	// Resume continues from the latest checkpoint.
+	// Resumed runs are not bit-exact continuations (see
+	// Checkpoint.Seed).
+	Resume bool `json:"resume" yaml:"resume"`
+}
+
+// A Checkpoint stores everything needed to resume
+// training.
+type Checkpoint struct {
+	Network  []byte
+	Adam     *AdamState
+	Schedule *ScheduleState
+
+	Epoch   int
+	Batches int
+
+	// Seconds is the total training time.
+	Seconds float64
+
+	// Stage is the current curriculum stage, and
+	// StageAccuracies holds the recent validation
+	// accuracies which decide when to advance past it.
+	Stage           int
+	StageAccuracies []float64
+
+	// Seed is the seed which training started with.
+	// Resumed runs reseed with Seed plus Batches, so they
+	// are reproducible, but they do not shuffle samples or
+	// drop out units the way an uninterrupted run would.
+	Seed int64
+
+	Validation     float64
+	BestValidation float64
+}
+
+// checkpointDir returns the checkpoint directory.
+func (t *TrainConfig) checkpointDir() string {
+	if t.Checkpoint.Dir != "" {
+		return t.Checkpoint.Dir
+	}
+	return t.NetworkFile + ".checkpoints"
+}
+
+// network decodes the checkpoint's network.
+func (c *Checkpoint) network() (*humancube.Network, error) {
+	return humancube.DeserializeNetwork(c.Network)
+}
+
+// resumeCheckpoint loads the latest checkpoint and its
+// network, converting the samples to match the network.
+func resumeCheckpoint(dir string, s *humancube.SampleSet) (*Checkpoint, *humancube.Network,
+	error) {
+	checkpoint, err := latestCheckpoint(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	net, err := checkpoint.network()
+	if err != nil {
+		return nil, nil, errors.New("decode checkpoint network: " + err.Error())
+	}
+	if net.Canonical {
+		if err := s.Canonicalize(net.Reference); err != nil {
+			return nil, nil, errors.New("canonicalize samples: " + err.Error())
+		}
+	}
+	log.Printf("Resuming from batch %d (epoch %d).", checkpoint.Batches, checkpoint.Epoch)
+	return checkpoint, net, nil
+}
+
+// checkpointDue checks if it is time for a periodic
+// checkpoint.
+func checkpointDue(opts *CheckpointOptions, batch int, last time.Time) bool {
+	if opts.Batches > 0 && batch%opts.Batches == 0 {
+		return true
+	}
+	return opts.Minutes > 0 && time.Since(last).Minutes() >= opts.Minutes
+}
+
+// saveCheckpoint writes a checkpoint and deletes all but
+// the keep latest ones.
+// If best is set, the checkpoint is also saved as the best
+// one.
+func saveCheckpoint(dir string, c *Checkpoint, net *humancube.Network, keep int,
+	best bool) error {
+	data, err := net.Serialize()
+	if err != nil {
+		return err
+	}
+	c.Network = data
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s%010d%s", checkpointPrefix, c.Batches, checkpointExt)
+	if err := writeCheckpoint(filepath.Join(dir, name), c); err != nil {
+		return err
+	}
+	if best {
+		if err := writeCheckpoint(filepath.Join(dir, bestCheckpoint), c); err != nil {
+			return err
+		}
+	}
+
+	names, err := checkpointNames(dir)
+	if err != nil {
+		return err
+	}
+	for keep > 0 && len(names) > keep {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// writeCheckpoint writes a checkpoint through a temporary
+// file, so that a crash never leaves a partial checkpoint.
+func writeCheckpoint(path string, c *Checkpoint) error {
+	tempPath := path + ".tmp"
+	f, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// latestCheckpoint loads the most recent checkpoint in a
+// directory.
+func latestCheckpoint(dir string) (*Checkpoint, error) {
+	names, err := checkpointNames(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no checkpoints in " + dir)
+	}
+	f, err := os.Open(filepath.Join(dir, names[len(names)-1]))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res Checkpoint
+	if err := gob.NewDecoder(f).Decode(&res); err != nil {
+		return nil, errors.New("decode checkpoint: " + err.Error())
+	}
+	return &res, nil
+}
+
+// checkpointNames lists the periodic checkpoints in a
+// directory from oldest to newest.
+func checkpointNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	allNames, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, name := range allNames {
+		if strings.HasPrefix(name, checkpointPrefix) && strings.HasSuffix(name, checkpointExt) {
+			res = append(res, name)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/humancube"
+	"gopkg.in/yaml.v2"
+)
+
+// These are the supported optimizers.
+const (
+	AdamOptimizer = "adam"
+	SGDOptimizer  = "sgd"
+)
+
+// ConfigSuffix is appended to the network's path to get
+// the path of the saved effective config.
+const ConfigSuffix = ".config.json"
+
+// MetricsSuffix is appended to the network's path to get
+// the default metrics log path.
+const MetricsSuffix = ".metrics.csv"
+
+// TrainConfig is everything which controls training.
+// It can be loaded from a YAML or JSON file, and flags
+// override the values from the file.
+type TrainConfig struct {
+	DataFile    string `json:"data_file" yaml:"data_file"`
+	NetworkFile string `json:"network_file" yaml:"network_file"`
+
+	Network   NetworkOptions   `json:"network" yaml:"network"`
+	Store     StoreOptions     `json:"store" yaml:"store"`
+	Augment   AugmentOptions   `json:"augment" yaml:"augment"`
+	Optimizer OptimizerOptions `json:"optimizer" yaml:"optimizer"`
+	Split     SplitOptions     `json:"split" yaml:"split"`
+	Seeds     SeedOptions      `json:"seeds" yaml:"seeds"`
+	Logging   LoggingOptions   `json:"logging" yaml:"logging"`
+
+	Checkpoint CheckpointOptions `json:"checkpoint" yaml:"checkpoint"`
+	Schedule   ScheduleOptions   `json:"schedule" yaml:"schedule"`
+	Parallel   ParallelOptions   `json:"parallel" yaml:"parallel"`
+	Curriculum CurriculumOptions `json:"curriculum" yaml:"curriculum"`
+	Weights    WeightOptions     `json:"weights" yaml:"weights"`
+}
+
+type AugmentOptions struct {
+	Crossover  int  `json:"crossover" yaml:"crossover"`
+	LLCases    int  `json:"ll_cases" yaml:"ll_cases"`
+	CrossSkips bool `json:"cross_skips" yaml:"cross_skips"`
+	FirstSkips bool `json:"first_skips" yaml:"first_skips"`
+}
+
+type OptimizerOptions struct {
+	Algorithm string  `json:"algorithm" yaml:"algorithm"`
+	StepSize  float64 `json:"step_size" yaml:"step_size"`
+	BatchSize int     `json:"batch_size" yaml:"batch_size"`
+
+	// These configure Adam.
+	// Zero values select the usual defaults.
+	DecayRate1 float64 `json:"decay_rate1" yaml:"decay_rate1"`
+	DecayRate2 float64 `json:"decay_rate2" yaml:"decay_rate2"`
+	Damping    float64 `json:"damping" yaml:"damping"`
+}
+
+type SplitOptions struct {
+	Strategy   string  `json:"strategy" yaml:"strategy"`
+	Validation float64 `json:"validation" yaml:"validation"`
+}
+
+// splitOptions returns the split, which is recorded in
+// the trained network.
+func (t *TrainConfig) splitOptions() *humancube.SplitOptions {
+	return &humancube.SplitOptions{
+		Strategy:   t.Split.Strategy,
+		Validation: t.Split.Validation,
+		Seed:       t.Seeds.Split,
+	}
+}
+
+type SeedOptions struct {
+	// Augment seeds augmentation, so that the training set
+	// is the same every time.
+	Augment int64 `json:"augment" yaml:"augment"`
+
+	// Init seeds the weights of new networks and the
+	// training order.
+	// If it is 0, the current time is used.
+	Init int64 `json:"init" yaml:"init"`
+
+	// Split seeds RandomSplit.
+	Split int64 `json:"split" yaml:"split"`
+}
+
+type ParallelOptions struct {
+	// Workers is the number of goroutines which compute
+	// gradients and prepare batches.
+	// With one worker, batches are prepared while computing
+	// gradients, as sgd.SGDMini does, unless they come from
+	// shards.
+	Workers int `json:"workers" yaml:"workers"`
+
+	// Prefetch is the number of batches to prepare ahead of
+	// time when there are multiple workers or shards.
+	Prefetch int `json:"prefetch" yaml:"prefetch"`
+}
+
+type LoggingOptions struct {
+	// Interval is the number of batches between logs.
+	Interval int `json:"interval" yaml:"interval"`
+
+	// MetricsFile is a CSV or JSON Lines file for metrics.
+	// If it is empty, the network file with MetricsSuffix
+	// appended is used.
+	MetricsFile string `json:"metrics_file" yaml:"metrics_file"`
+}
+
+// DefaultTrainConfig returns the config used by flags
+// which are not set.
+func DefaultTrainConfig() *TrainConfig {
+	return &TrainConfig{
+		Network: NetworkOptions{
+			Encoder:     humancube.DefaultEncoder,
+			Reference:   humancube.DefaultReference,
+			Cell:        humancube.LSTMCell,
+			Hidden:      "150,150",
+			Dropout:     "0.5,0.5",
+			Value:       humancube.ValueNone,
+			ValueWeight: 1,
+		},
+		Store: StoreOptions{
+			ShardSize: humancube.DefaultShardSize,
+			CacheSize: humancube.DefaultShardCache,
+		},
+		Augment: AugmentOptions{
+			Crossover:  30000,
+			LLCases:    3,
+			CrossSkips: true,
+			FirstSkips: true,
+		},
+		Optimizer: OptimizerOptions{Algorithm: AdamOptimizer},
+		Split: SplitOptions{
+			Strategy:   humancube.HashSplit,
+			Validation: humancube.DefaultValidationAmount,
+		},
+		Seeds:   SeedOptions{Augment: 123123, Split: 1},
+		Logging: LoggingOptions{Interval: 4},
+		Checkpoint: CheckpointOptions{
+			Batches:        500,
+			Minutes:        10,
+			Keep:           3,
+			ValidationSize: 512,
+		},
+		Parallel:   ParallelOptions{Workers: 1, Prefetch: 4},
+		Curriculum: CurriculumOptions{Window: 5},
+		Weights:    WeightOptions{Augmented: 1},
+		Schedule: ScheduleOptions{
+			Kind:          ConstantSchedule,
+			StepFactor:    0.5,
+			PlateauFactor: 0.5,
+		},
+	}
+}
+
+// AddFlags registers a flag for every field of the config,
+// using the current values as defaults.
+func (t *TrainConfig) AddFlags(f *flag.FlagSet) {
+	n := &t.Network
+	f.StringVar(&t.DataFile, "data", t.DataFile, "reconstruction data file")
+	f.StringVar(&t.NetworkFile, "out", t.NetworkFile, "network file to load and save")
+
+	f.StringVar(&n.Encoder, "encoder", n.Encoder,
+		"input encoding for new networks ("+strings.Join(humancube.EncoderNames(), ", ")+")")
+	f.BoolVar(&n.Canonical, "canonical", n.Canonical,
+		"train new networks on orientation-canonical samples")
+	f.StringVar(&n.Reference, "reference", n.Reference, "reference rotations for canonical samples")
+	f.StringVar(&n.Cell, "cell", n.Cell, "hidden layer type (lstm, gru, vanilla, dense)")
+	f.StringVar(&n.Hidden, "hidden", n.Hidden, "comma-separated hidden layer sizes")
+	f.StringVar(&n.Dropout, "dropout", n.Dropout,
+		"comma-separated keep probabilities after each hidden layer (the last one repeats)")
+	f.StringVar(&n.PreLayers, "prelayers", n.PreLayers,
+		"comma-separated sizes of dense layers before the hidden layers")
+	f.IntVar(&n.History, "history", n.History, "number of previous moves to feed to the network")
+	f.BoolVar(&n.Phase, "phase", n.Phase, "feed the detected solve phase to the network")
+	f.StringVar(&n.Methods, "methods", n.Methods,
+		"comma-separated methods to condition on (e.g. "+
+			strings.Join(humancube.DefaultMethods, ",")+")")
+	f.BoolVar(&n.Solvers, "solvers", n.Solvers, "condition on the solvers in the data")
+	f.StringVar(&n.Value, "value", n.Value,
+		"value head to predict moves remaining in the solve or phase (solve, phase)")
+	f.Float64Var(&n.ValueWeight, "valueweight", n.ValueWeight, "cost weight of the value head")
+
+	f.StringVar(&t.Store.Dir, "shards", t.Store.Dir,
+		"stream training samples from shards in this directory")
+	f.IntVar(&t.Store.ShardSize, "shardsize", t.Store.ShardSize, "samples per shard")
+	f.IntVar(&t.Store.CacheSize, "shardcache", t.Store.CacheSize, "shards to keep in memory")
+
+	f.IntVar(&t.Augment.Crossover, "crossover", t.Augment.Crossover,
+		"number of crossover samples to generate")
+	f.IntVar(&t.Augment.LLCases, "llcases", t.Augment.LLCases,
+		"last layer cases to generate per solve")
+	f.BoolVar(&t.Augment.CrossSkips, "crossskips", t.Augment.CrossSkips,
+		"generate samples starting with a solved cross")
+	f.BoolVar(&t.Augment.FirstSkips, "firstskips", t.Augment.FirstSkips,
+		"generate samples with the first move made")
+
+	f.StringVar(&t.Optimizer.Algorithm, "optimizer", t.Optimizer.Algorithm, "optimizer (adam, sgd)")
+	f.Float64Var(&t.Optimizer.StepSize, "step", t.Optimizer.StepSize, "step size")
+	f.IntVar(&t.Optimizer.BatchSize, "batch", t.Optimizer.BatchSize, "batch size")
+
+	f.StringVar(&t.Split.Strategy, "split", t.Split.Strategy,
+		"validation split strategy (hash, random)")
+	f.Float64Var(&t.Split.Validation, "validation", t.Split.Validation,
+		"fraction of samples to hold out for validation")
+
+	f.Int64Var(&t.Seeds.Augment, "augseed", t.Seeds.Augment, "augmentation seed")
+	f.Int64Var(&t.Seeds.Init, "initseed", t.Seeds.Init,
+		"initialization and shuffling seed (0 for the time)")
+	f.Int64Var(&t.Seeds.Split, "splitseed", t.Seeds.Split, "seed for the random split")
+
+	f.IntVar(&t.Logging.Interval, "loginterval", t.Logging.Interval, "batches between logs")
+	f.StringVar(&t.Logging.MetricsFile, "metrics", t.Logging.MetricsFile,
+		"CSV or JSON Lines metrics log (default: network file plus "+MetricsSuffix+")")
+
+	c := &t.Checkpoint
+	f.StringVar(&c.Dir, "ckptdir", c.Dir,
+		"checkpoint directory (default: network file plus .checkpoints)")
+	f.IntVar(&c.Batches, "ckptbatches", c.Batches, "batches between checkpoints (0 to disable)")
+	f.Float64Var(&c.Minutes, "ckptminutes", c.Minutes, "minutes between checkpoints (0 to disable)")
+	f.IntVar(&c.Keep, "ckptkeep", c.Keep, "number of recent checkpoints to keep (0 for all)")
+	f.IntVar(&c.ValidationSize, "ckptval", c.ValidationSize,
+		"validation samples used to track the best checkpoint")
+	f.BoolVar(&c.Resume, "resume", c.Resume, "resume from the latest checkpoint")
+
+	f.IntVar(&t.Parallel.Workers, "workers", t.Parallel.Workers,
+		"goroutines for gradients and batch preparation")
+	f.IntVar(&t.Parallel.Prefetch, "prefetch", t.Parallel.Prefetch,
+		"batches to prepare ahead of time with multiple workers")
+
+	f.Float64Var(&t.Weights.Augmented, "augweight", t.Weights.Augmented,
+		"cost weight of augmented samples relative to human solves")
+	f.Var(&t.Weights.Origins, "originweights",
+		"extra weights for augmentations, like \"crossover=0.5,last layer=0.8\"")
+	f.Var(&t.Weights.Methods, "methodweights", "weights for methods, like \"CFOP=1,Roux=0.5\"")
+	f.Var(&t.Weights.Solvers, "solverweights", "weights for solvers, like \"name=2\"")
+
+	f.Var(&t.Curriculum.Thresholds, "curriculum", "comma-separated validation accuracies "+
+		"needed to advance past each curriculum stage but the last (empty for no curriculum)")
+	f.IntVar(&t.Curriculum.Window, "curriculumwindow", t.Curriculum.Window,
+		"logged accuracies to average for the curriculum")
+
+	sc := &t.Schedule
+	f.StringVar(&sc.Kind, "schedule", sc.Kind, "step size schedule (constant, step, cosine)")
+	f.IntVar(&sc.WarmupBatches, "warmup", sc.WarmupBatches, "batches of linear warmup")
+	f.IntVar(&sc.StepBatches, "decaybatches", sc.StepBatches, "batches between step decays")
+	f.Float64Var(&sc.StepFactor, "decayfactor", sc.StepFactor, "step decay factor")
+	f.IntVar(&sc.CosineBatches, "cosinebatches", sc.CosineBatches, "length of the cosine schedule")
+	f.Float64Var(&sc.MinFactor, "minfactor", sc.MinFactor,
+		"final fraction of the step size for the cosine schedule")
+	f.IntVar(&sc.PlateauPatience, "plateau", sc.PlateauPatience,
+		"checkpoints without improvement before reducing the step size (0 to disable)")
+	f.Float64Var(&sc.PlateauFactor, "plateaufactor", sc.PlateauFactor,
+		"step size reduction on plateaus")
+	f.IntVar(&sc.EarlyStopPatience, "patience", sc.EarlyStopPatience,
+		"checkpoints without improvement before stopping (0 to disable)")
+}
+
+// Load reads a YAML or JSON config file into the config.
+// Fields which are not in the file are left alone.
+func (t *TrainConfig) Load(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.New("read config: " + err.Error())
+	}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, t)
+	} else {
+		err = yaml.Unmarshal(data, t)
+	}
+	if err != nil {
+		return errors.New("parse config: " + err.Error())
+	}
+	return nil
+}
+
+// Save writes the config to a JSON file.
+func (t *TrainConfig) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Validate checks that the config is usable.
+func (t *TrainConfig) Validate() error {
+	if t.DataFile == "" {
+		return errors.New("missing data file")
+	} else if t.NetworkFile == "" {
+		return errors.New("missing network file")
+	}
+	if _, err := humancube.EncoderForName(t.Network.Encoder); err != nil {
+		return err
+	}
+	if t.Network.ValueWeight < 0 {
+		return errors.New("value weight must not be negative")
+	}
+	if t.Store.ShardSize <= 0 || t.Store.CacheSize <= 0 {
+		return errors.New("shard size and cache size must be positive")
+	}
+	if t.Augment.Crossover < 0 || t.Augment.LLCases < 0 {
+		return errors.New("augmentation counts must not be negative")
+	}
+	switch t.Optimizer.Algorithm {
+	case AdamOptimizer, SGDOptimizer:
+	default:
+		return errors.New("unknown optimizer: " + t.Optimizer.Algorithm)
+	}
+	if t.Optimizer.StepSize <= 0 {
+		return errors.New("step size must be positive")
+	} else if t.Optimizer.BatchSize <= 0 {
+		return errors.New("batch size must be positive")
+	}
+	if err := t.splitOptions().Validate(); err != nil {
+		return err
+	}
+	if t.Logging.Interval <= 0 {
+		return errors.New("log interval must be positive")
+	}
+	if t.Parallel.Workers <= 0 || t.Parallel.Prefetch <= 0 {
+		return errors.New("workers and prefetch must be positive")
+	}
+	if err := t.Weights.Validate(); err != nil {
+		return err
+	}
+	if err := t.Curriculum.Validate(); err != nil {
+		return err
+	}
+	if err := t.Schedule.Validate(); err != nil {
+		return err
+	}
+	c := &t.Checkpoint
+	if c.Batches < 0 || c.Minutes < 0 || c.Keep < 0 {
+		return errors.New("checkpoint options must not be negative")
+	} else if c.ValidationSize <= 0 {
+		return errors.New("checkpoint validation size must be positive")
+	}
+	return nil
+}
+
+// metricsFile returns the path of the metrics log.
+func (t *TrainConfig) metricsFile() string {
+	if t.Logging.MetricsFile != "" {
+		return t.Logging.MetricsFile
+	}
+	return t.NetworkFile + MetricsSuffix
+}
+
+// AugmentParams returns the augmentation parameters.
+func (a *AugmentOptions) AugmentParams() *humancube.AugmentParams {
+	return &humancube.AugmentParams{
+		Crossover:  a.Crossover,
+		LLCases:    a.LLCases,
+		CrossSkips: a.CrossSkips,
+		FirstSkips: a.FirstSkips,
+	}
+}
+
+type WeightOptions struct {
+	Augmented float64   `json:"augmented" yaml:"augmented"`
+	Origins   weightMap `json:"origins" yaml:"origins"`
+	Methods   weightMap `json:"methods" yaml:"methods"`
+	Solvers   weightMap `json:"solvers" yaml:"solvers"`
+}
+
+// Validate checks that every weight is positive.
+func (w *WeightOptions) Validate() error {
+	if w.Augmented <= 0 {
+		return errors.New("augmented weight must be positive")
+	}
+	for _, m := range []weightMap{w.Origins, w.Methods, w.Solvers} {
+		for name, weight := range m {
+			if weight <= 0 {
+				return errors.New("weight must be positive: " + name)
+			}
+		}
+	}
+	return nil
+}
+
+// SampleWeights returns the weights to apply to the
+// training samples.
+func (w *WeightOptions) SampleWeights() *humancube.SampleWeights {
+	return &humancube.SampleWeights{
+		Augmented: w.Augmented,
+		Origins:   w.Origins,
+		Methods:   w.Methods,
+		Solvers:   w.Solvers,
+	}
+}
+
+// weightMap maps names to weights and can be set by a flag
+// like "name1=weight1,name2=weight2".
+type weightMap map[string]float64
+
+func (w *weightMap) String() string {
+	var names []string
+	for name := range *w {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, name+"="+strconv.FormatFloat((*w)[name], 'g', -1, 64))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (w *weightMap) Set(s string) error {
+	res := weightMap{}
+	for _, field := range splitList(s) {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return errors.New("expected name=weight but got: " + field)
+		}
+		weight, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return err
+		}
+		res[parts[0]] = weight
+	}
+	*w = res
+	return nil
+}
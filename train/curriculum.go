@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/humancube"
+	"github.com/unixpickle/sgd"
+)
+
+type CurriculumOptions struct {
+	// Thresholds contains, for every curriculum stage but
+	// the last, the validation accuracy needed to advance to
+	// the next stage.
+	// If it is empty, there is no curriculum.
+	Thresholds floatList `json:"thresholds" yaml:"thresholds"`
+
+	// Window is the number of logged accuracies which are
+	// averaged before comparing to a threshold.
+	Window int `json:"window" yaml:"window"`
+}
+
+// Enabled checks if there is a curriculum.
+func (c *CurriculumOptions) Enabled() bool {
+	return len(c.Thresholds) > 0
+}
+
+// Validate checks that the options are usable.
+func (c *CurriculumOptions) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if len(c.Thresholds) != len(humancube.CurriculumStages)-1 {
+		return fmt.Errorf("expected %d curriculum thresholds but got %d",
+			len(humancube.CurriculumStages)-1, len(c.Thresholds))
+	}
+	if c.Window <= 0 {
+		return errors.New("curriculum window must be positive")
+	}
+	return nil
+}
+
+// curriculumTracker decides when to advance to the next
+// curriculum stage.
+type curriculumTracker struct {
+	Options *CurriculumOptions
+	recent  []float64
+}
+
+// Add records a validation accuracy for a stage and
+// returns true if training should advance to the next
+// stage.
+func (c *curriculumTracker) Add(stage int, accuracy float64) bool {
+	if stage >= len(c.Options.Thresholds) {
+		return false
+	}
+	c.recent = append(c.recent, accuracy)
+	if len(c.recent) > c.Options.Window {
+		c.recent = c.recent[1:]
+	}
+	if len(c.recent) < c.Options.Window {
+		return false
+	}
+	var sum float64
+	for _, x := range c.recent {
+		sum += x
+	}
+	if sum/float64(len(c.recent)) < c.Options.Thresholds[stage] {
+		return false
+	}
+	c.recent = nil
+	return true
+}
+
+// curriculumSets splits the training and validation
+// samples into curriculum stages.
+// Without a curriculum, there is one stage with all of the
+// samples.
+func curriculumSets(c *CurriculumOptions, training,
+	validation *humancube.SampleSet) (trainingSets, validationSets []sgd.SampleSet) {
+	if !c.Enabled() {
+		return []sgd.SampleSet{training}, []sgd.SampleSet{validation}
+	}
+	for i := range humancube.CurriculumStages {
+		trainingSets = append(trainingSets, training.CurriculumSubset(i))
+	}
+	return trainingSets, curriculumValidation(c, validation)
+}
+
+// curriculumValidation is like curriculumSets, but for the
+// validation samples alone.
+func curriculumValidation(c *CurriculumOptions,
+	validation *humancube.SampleSet) []sgd.SampleSet {
+	if !c.Enabled() {
+		return []sgd.SampleSet{validation}
+	}
+	var res []sgd.SampleSet
+	for i := range humancube.CurriculumStages {
+		res = append(res, validation.CurriculumSubset(i))
+	}
+	return res
+}
+
+// numStages returns the number of curriculum stages.
+func (c *CurriculumOptions) numStages() int {
+	if !c.Enabled() {
+		return 1
+	}
+	return len(humancube.CurriculumStages)
+}
+
+// floatList is a list of floats which can be set by a
+// comma-separated flag.
+type floatList []float64
+
+func (f *floatList) String() string {
+	var parts []string
+	for _, x := range *f {
+		parts = append(parts, strconv.FormatFloat(x, 'g', -1, 64))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *floatList) Set(s string) error {
+	var res floatList
+	for _, field := range splitList(s) {
+		x, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return err
+		}
+		res = append(res, x)
+	}
+	*f = res
+	return nil
+}
+
+// stageShardDir returns the shard directory for a
+// curriculum stage.
+func stageShardDir(dir string, stage, numStages int) string {
+	if numStages == 1 {
+		return dir
+	}
+	return filepath.Join(dir, fmt.Sprintf("stage%d", stage))
+}
@@ -2,14 +2,17 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"math"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/unixpickle/gocube"
 	"github.com/unixpickle/humancube"
 	"github.com/unixpickle/num-analysis/linalg"
 	"github.com/unixpickle/serializer"
@@ -19,99 +22,292 @@ import (
 	"github.com/unixpickle/weakai/rnn/seqtoseq"
 )
 
-const (
-	ValidationAmount = 0.1
-)
-
 type SolveData struct {
 	InSeqs  [][]linalg.Vector
 	OutSeqs [][]linalg.Vector
 	MoveMap map[string]int
 }
 
+type NetworkOptions struct {
+	Encoder   string `json:"encoder" yaml:"encoder"`
+	Canonical bool   `json:"canonical" yaml:"canonical"`
+	Reference string `json:"reference" yaml:"reference"`
+
+	Cell      string `json:"cell" yaml:"cell"`
+	Hidden    string `json:"hidden" yaml:"hidden"`
+	Dropout   string `json:"dropout" yaml:"dropout"`
+	PreLayers string `json:"pre_layers" yaml:"pre_layers"`
+	History   int    `json:"history" yaml:"history"`
+	Phase     bool   `json:"phase" yaml:"phase"`
+	Methods   string `json:"methods" yaml:"methods"`
+	Solvers   bool   `json:"solvers" yaml:"solvers"`
+	Value     string `json:"value" yaml:"value"`
+
+	ValueWeight float64 `json:"value_weight" yaml:"value_weight"`
+}
+
+type StoreOptions struct {
+	Dir       string `json:"dir" yaml:"dir"`
+	ShardSize int    `json:"shard_size" yaml:"shard_size"`
+	CacheSize int    `json:"cache_size" yaml:"cache_size"`
+}
+
 func main() {
-	if len(os.Args) != 5 {
+	config := DefaultTrainConfig()
+	var configFile string
+	flag.StringVar(&configFile, "config", "", "YAML or JSON config file (flags override it)")
+	config.AddFlags(flag.CommandLine)
+	flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0],
-			"data_file network_file step_size batch_size")
+			"[flags] [data_file network_file step_size batch_size]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 && flag.NArg() != 4 {
+		flag.Usage()
 		os.Exit(1)
 	}
-	if err := RunCommand(); err != nil {
+	if err := RunCommand(config, configFile); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
-func RunCommand() error {
-	stepSize, err := strconv.ParseFloat(os.Args[3], 64)
-	if err != nil {
-		return errors.New("bad step size")
+func RunCommand(config *TrainConfig, configFile string) error {
+	if configFile != "" {
+		// Flags take precedence over the file, so they are
+		// re-applied after loading it.
+		setFlags := map[string]string{}
+		flag.Visit(func(f *flag.Flag) {
+			setFlags[f.Name] = f.Value.String()
+		})
+		if err := config.Load(configFile); err != nil {
+			return err
+		}
+		for name, value := range setFlags {
+			flag.Set(name, value)
+		}
 	}
-	batchSize, err := strconv.Atoi(os.Args[4])
-	if err != nil {
-		return errors.New("bad batch size")
+	if flag.NArg() == 4 {
+		config.DataFile = flag.Arg(0)
+		config.NetworkFile = flag.Arg(1)
+		var err error
+		config.Optimizer.StepSize, err = strconv.ParseFloat(flag.Arg(2), 64)
+		if err != nil {
+			return errors.New("bad step size")
+		}
+		config.Optimizer.BatchSize, err = strconv.Atoi(flag.Arg(3))
+		if err != nil {
+			return errors.New("bad batch size")
+		}
 	}
-	return Train(os.Args[1], os.Args[2], stepSize, batchSize)
+	if err := config.Validate(); err != nil {
+		return errors.New("invalid config: " + err.Error())
+	}
+	return Train(config)
 }
 
-func Train(solveFile, outFile string, stepSize float64, batchSize int) error {
-	sampleSet, err := humancube.LoadSampleSet(solveFile)
+func Train(config *TrainConfig) error {
+	batchSize := config.Optimizer.BatchSize
+	sampleSet, err := humancube.LoadSampleSet(config.DataFile)
 	if err != nil {
 		return errors.New("load sample set: " + err.Error())
 	}
 
+	initSeed := config.Seeds.Init
+	if initSeed == 0 {
+		initSeed = time.Now().UnixNano()
+	}
+	var net *humancube.Network
+	var checkpoint *Checkpoint
+	if config.Checkpoint.Resume {
+		checkpoint, net, err = resumeCheckpoint(config.checkpointDir(), sampleSet)
+		if err == nil {
+			config.Network.useNetwork(net)
+		}
+	} else {
+		net, err = loadNetwork(config.NetworkFile, sampleSet, &config.Network, initSeed)
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("Using encoder:", net.Encoder.Name())
+	sampleSet.Encoder = net.Encoder
+	sampleSet.Inputs = net.Config.InputConfig
+	sampleSet.Outputs = net.Config.OutputConfig
+
+	configPath := config.NetworkFile + ConfigSuffix
+	if err := config.Save(configPath); err != nil {
+		return errors.New("save config: " + err.Error())
+	}
+	log.Println("Saved effective config to", configPath)
+
 	// It is important to split before augmenting, to ensure
 	// that the validation set doesn't include samples which
 	// are closely related to the training set.
-	validation, training := sgd.HashSplit(sampleSet, ValidationAmount)
-	if validation.Len() < batchSize || training.Len() < batchSize {
+	// The split is saved with the network, so that other
+	// tools can find the held-out samples.
+	net.Split = config.splitOptions()
+	heldOut, trainingSamples := net.SplitSamples(sampleSet)
+	if heldOut.Len() < batchSize || trainingSamples.Len() < batchSize {
 		return errors.New("not enough samples")
 	}
-	validation = validation.Copy()
-	training = training.Copy()
 
-	log.Printf("Augmenting %d training and %d validation...", training.Len(), validation.Len())
-	// Augment the samples the same way every time.
-	rand.Seed(123123)
-	augParams := &humancube.AugmentParams{
-		Crossover:  30000,
-		LLCases:    3,
-		CrossSkips: true,
-		FirstSkips: true,
+	var trainingSets, validationSets []sgd.SampleSet
+	if config.Store.Dir != "" {
+		// Augmented samples are streamed to disk, so that
+		// they never have to fit in memory.
+		trainingSets, err = storeTrainingSets(config, net, trainingSamples)
+		if err != nil {
+			return err
+		}
+		validationSets = curriculumValidation(&config.Curriculum, heldOut)
+	} else {
+		log.Printf("Augmenting %d training samples...", trainingSamples.Len())
+		// Augment the samples the same way every time.
+		rand.Seed(config.Seeds.Augment)
+		humancube.Augment(trainingSamples, config.Augment.AugmentParams())
+		trainingSamples.ApplyWeights(config.Weights.SampleWeights())
+		trainingSets, validationSets = curriculumSets(&config.Curriculum, trainingSamples,
+			heldOut)
+	}
+	if checkpoint == nil {
+		rand.Seed(initSeed)
+	} else {
+		if checkpoint.Seed == 0 {
+			checkpoint.Seed = initSeed
+		}
+		rand.Seed(checkpoint.Seed + int64(checkpoint.Batches))
 	}
-	humancube.Augment(training.(*humancube.SampleSet), augParams)
-	log.Printf("Using %d training and %d validation...", training.Len(), validation.Len())
+	for i, set := range trainingSets {
+		if set.Len() < batchSize || validationSets[i].Len() < batchSize {
+			if len(trainingSets) == 1 {
+				return errors.New("not enough samples")
+			}
+			return errors.New("not enough samples for curriculum stage: " +
+				humancube.CurriculumStages[i].Name)
+		}
+		if len(trainingSets) > 1 {
+			log.Printf("Curriculum stage %q has %d training and %d validation samples.",
+				humancube.CurriculumStages[i].Name, set.Len(), validationSets[i].Len())
+		} else {
+			log.Printf("Using %d training and %d validation...", set.Len(),
+				validationSets[i].Len())
+		}
+	}
+	var training, validation sgd.SampleSet
 
-	net, err := humancube.ReadNetwork(outFile)
-	if err != nil {
-		log.Println("Could not load network. Creating new one.")
-		rand.Seed(time.Now().UnixNano())
-		solved := gocube.SolvedCubieCube()
-		inLen := len(humancube.CubeVector(&solved))
-		net = humancube.NewNetwork(inLen, sampleSet.MoveMap)
+	costFunc := net.CostFunc(config.Network.ValueWeight)
+	workers := config.Parallel.Workers
+	var gradienter sgd.Gradienter
+	if workers > 1 {
+		log.Printf("Computing gradients on %d workers.", workers)
+		parallel := &parallelGradienter{}
+		for i := 0; i < workers; i++ {
+			parallel.Gradienters = append(parallel.Gradienters,
+				blockGradienter(net, costFunc, (batchSize+workers-1)/workers))
+		}
+		gradienter = parallel
 	} else {
-		log.Println("Loaded existing network from file.")
+		gradienter = blockGradienter(net, costFunc, batchSize)
 	}
+	var adam *Adam
+	if config.Optimizer.Algorithm == AdamOptimizer {
+		adam = &Adam{
+			Gradienter: gradienter,
+			Params:     net.Block.Parameters(),
+			DecayRate1: config.Optimizer.DecayRate1,
+			DecayRate2: config.Optimizer.DecayRate2,
+			Damping:    config.Optimizer.Damping,
+		}
+		gradienter = adam
+	}
+
+	if checkpoint == nil {
+		checkpoint = &Checkpoint{BestValidation: math.Inf(1), Seed: initSeed}
+	} else if adam != nil && checkpoint.Adam != nil {
+		adam.State = *checkpoint.Adam
+	}
+	if checkpoint.Schedule == nil {
+		checkpoint.Schedule = NewScheduleState()
+	}
+	schedule := &config.Schedule
+	scaled := &scaledGradienter{Gradienter: gradienter}
 
-	costFunc := neuralnet.DotCost{}
-	gradienter := &sgd.Adam{
-		Gradienter: &seqtoseq.Gradienter{
-			SeqFunc:  &rnn.BlockSeqFunc{B: net.Block},
-			Learner:  net.Block,
-			CostFunc: costFunc,
-			MaxLanes: batchSize,
-		},
+	// The best checkpoint is judged on a fixed subset of the
+	// current stage's validation samples.
+	var bestVal sgd.SampleSet
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	metricsPath := config.metricsFile()
+	metrics, err := humancube.OpenMetricsLog(metricsPath)
+	if err != nil {
+		return errors.New("open metrics log: " + err.Error())
+	}
+	defer metrics.Close()
+	log.Println("Logging metrics to", metricsPath)
+
+	startTime := time.Now()
+	startSeconds := checkpoint.Seconds
+	elapsed := func() float64 {
+		return startSeconds + time.Since(startTime).Seconds()
+	}
+
+	tracker := &curriculumTracker{
+		Options: &config.Curriculum,
+		recent:  checkpoint.StageAccuracies,
+	}
+
+	// checkpointNow evaluates the network, updates the
+	// schedule and saves a checkpoint.
+	// It returns true if training should stop early.
+	savedBatch := -1
+	checkpointNow := func() (bool, error) {
+		checkpoint.Epoch = checkpoint.Batches * batchSize / training.Len()
+		checkpoint.Seconds = elapsed()
+		checkpoint.Validation = seqtoseq.TotalCostBlock(net.Block, batchSize, bestVal,
+			costFunc) / float64(bestVal.Len())
+		best := checkpoint.Validation < checkpoint.BestValidation
+		if best {
+			checkpoint.BestValidation = checkpoint.Validation
+		}
+		stop := schedule.Update(checkpoint.Schedule, best)
+		if adam != nil {
+			checkpoint.Adam = &adam.State
+		}
+		checkpoint.StageAccuracies = tracker.recent
+		log.Printf("Checkpoint at batch %d: validation=%f best=%v step=%g", checkpoint.Batches,
+			checkpoint.Validation, best, schedule.StepSize(config.Optimizer.StepSize,
+				checkpoint.Schedule, checkpoint.Batches))
+		savedBatch = checkpoint.Batches
+		return stop, saveCheckpoint(config.checkpointDir(), checkpoint, net,
+			config.Checkpoint.Keep, best)
 	}
 
 	log.Println("Training (Ctrl+C to finish)...")
 
 	net.Dropout(true)
-	var epochIdx int
 	var lastBatch sgd.SampleSet
-	sgd.SGDMini(gradienter, training, stepSize, batchSize, func(s sgd.SampleSet) bool {
+	var trainErr error
+	lastCheckpoint := time.Now()
+	startBatch := checkpoint.Batches
+	var advance bool
+	status := func(s sgd.SampleSet) bool {
 		net.Dropout(false)
 		defer net.Dropout(true)
 
-		if epochIdx%4 == 0 {
+		select {
+		case <-interrupt:
+			log.Println("Caught interrupt.")
+			return false
+		default:
+		}
+
+		epochIdx := checkpoint.Batches
+		if epochIdx%config.Logging.Interval == 0 {
 			var lastCost float64
 			if lastBatch != nil {
 				lastCost = seqtoseq.TotalCostBlock(net.Block, batchSize, lastBatch, costFunc)
@@ -126,14 +322,237 @@ func Train(solveFile, outFile string, stepSize float64, batchSize int) error {
 
 			log.Printf("Epoch %d: validation=%f cost=%f last=%f", epochIdx, validationCost,
 				batchCost, lastCost)
+
+			accuracy := net.Imitate(subVal.(*humancube.SampleSet), batchSize).Total
+			row := &humancube.MetricsRow{
+				Batch:          epochIdx,
+				Epoch:          epochIdx * batchSize / training.Len(),
+				TrainCost:      batchCost / float64(s.Len()),
+				ValidationCost: validationCost / float64(subVal.Len()),
+				Accuracy:       accuracy.Top1Accuracy(),
+				StepSize: schedule.StepSize(config.Optimizer.StepSize, checkpoint.Schedule,
+					epochIdx),
+				Seconds: elapsed(),
+			}
+			if err := metrics.Write(row); err != nil {
+				trainErr = errors.New("write metrics: " + err.Error())
+				return false
+			}
+
+			if len(trainingSets) > 1 && tracker.Add(checkpoint.Stage, row.Accuracy) {
+				log.Println("Validation accuracy reached the curriculum threshold.")
+				advance = true
+				return false
+			}
+		}
+
+		if epochIdx > startBatch && checkpointDue(&config.Checkpoint, epochIdx,
+			lastCheckpoint) {
+			stop, err := checkpointNow()
+			if err != nil {
+				trainErr = errors.New("save checkpoint: " + err.Error())
+				return false
+			} else if stop {
+				log.Println("Validation stopped improving. Stopping early.")
+				return false
+			}
+			lastCheckpoint = time.Now()
 		}
 
-		epochIdx++
+		scaled.Scale = schedule.StepSize(config.Optimizer.StepSize, checkpoint.Schedule,
+			checkpoint.Batches)
+		checkpoint.Batches++
 		return true
-	})
+	}
+
+	if checkpoint.Stage >= len(trainingSets) {
+		checkpoint.Stage = len(trainingSets) - 1
+	}
+	for {
+		stage := checkpoint.Stage
+		training = trainingSets[stage]
+		validation = validationSets[stage]
+		bestVal = validation.Copy()
+		if bestVal.Len() > config.Checkpoint.ValidationSize {
+			bestVal = bestVal.Subset(0, config.Checkpoint.ValidationSize)
+		}
+		if len(trainingSets) > 1 {
+			log.Printf("Training on curriculum stage %q.",
+				humancube.CurriculumStages[stage].Name)
+		}
+
+		// The scaled gradienter applies the step size, so SGD
+		// itself uses a step size of 1.
+		advance = false
+		if _, isStore := training.(*humancube.StoreSampleSet); isStore || workers > 1 {
+			sgdPrefetch(scaled, training, 1, batchSize, workers, config.Parallel.Prefetch, status)
+		} else {
+			sgd.SGDMini(scaled, training, 1, batchSize, status)
+		}
+		if !advance {
+			break
+		}
+		checkpoint.Stage++
+
+		// Costs on different stages are not comparable, so
+		// the best checkpoint, plateaus and early stopping
+		// start over for the new stage.
+		checkpoint.BestValidation = math.Inf(1)
+		checkpoint.Schedule = NewScheduleState()
+	}
 
 	net.Dropout(false)
 
+	if trainErr != nil {
+		return trainErr
+	}
+	if checkpoint.Batches != savedBatch {
+		if _, err := checkpointNow(); err != nil {
+			return errors.New("save checkpoint: " + err.Error())
+		}
+	}
+
 	log.Println("Saving...")
-	return serializer.SaveAny(outFile, net)
+	return serializer.SaveAny(config.NetworkFile, net)
+}
+
+// blockGradienter creates a Gradienter for the network's
+// block.
+// Every worker needs its own Gradienter, since they keep
+// per-call state.
+func blockGradienter(net *humancube.Network, costFunc neuralnet.CostFunc,
+	maxLanes int) sgd.Gradienter {
+	return &seqtoseq.Gradienter{
+		SeqFunc:  &rnn.BlockSeqFunc{B: net.Block},
+		Learner:  net.Block,
+		CostFunc: costFunc,
+		MaxLanes: maxLanes,
+	}
+}
+
+// loadNetwork loads the network being trained or creates
+// a new one, converting the samples to match it.
+func loadNetwork(path string, s *humancube.SampleSet, opts *NetworkOptions,
+	seed int64) (*humancube.Network, error) {
+	net, err := humancube.ReadNetwork(path)
+	if err == nil {
+		log.Println("Loaded existing network from file.")
+		opts.useNetwork(net)
+		if net.Canonical {
+			if err := s.Canonicalize(net.Reference); err != nil {
+				return nil, errors.New("canonicalize samples: " + err.Error())
+			}
+		}
+		return net, nil
+	}
+
+	log.Println("Could not load network. Creating new one.")
+	encoder, err := humancube.EncoderForName(opts.Encoder)
+	if err != nil {
+		return nil, err
+	}
+	config, err := opts.Config(s)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Canonical {
+		if err := s.Canonicalize(opts.Reference); err != nil {
+			return nil, errors.New("canonicalize samples: " + err.Error())
+		}
+	}
+	rand.Seed(seed)
+	net, err = humancube.NewNetwork(encoder, s.MoveMap, config)
+	if err != nil {
+		return nil, err
+	}
+	net.Canonical = opts.Canonical
+	net.Reference = opts.Reference
+	return net, nil
+}
+
+// Config creates the architecture described by the
+// options for the given samples.
+func (n *NetworkOptions) Config(s *humancube.SampleSet) (*humancube.NetworkConfig, error) {
+	res := &humancube.NetworkConfig{Cell: n.Cell}
+	res.History = n.History
+	res.Phase = n.Phase
+	res.Value = n.Value
+	res.Methods = splitList(n.Methods)
+	if n.Solvers {
+		res.Solvers = s.Solvers()
+		log.Printf("Conditioning on %d solvers.", len(res.Solvers))
+	}
+	var err error
+	if res.HiddenSizes, err = parseInts(n.Hidden); err != nil {
+		return nil, errors.New("bad hidden sizes: " + err.Error())
+	}
+	if res.PreLayers, err = parseInts(n.PreLayers); err != nil {
+		return nil, errors.New("bad pre-layer sizes: " + err.Error())
+	}
+	for _, field := range splitList(n.Dropout) {
+		keep, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, errors.New("bad dropout: " + err.Error())
+		}
+		res.Dropout = append(res.Dropout, keep)
+	}
+	return res, nil
+}
+
+// useNetwork replaces the architecture options with those
+// of a loaded network, so that the saved config describes
+// the network which is actually trained.
+// It warns if the options asked for something else.
+func (n *NetworkOptions) useNetwork(net *humancube.Network) {
+	c := net.Config
+	dropout := floatList(c.Dropout)
+	actual := NetworkOptions{
+		Encoder:     net.Encoder.Name(),
+		Canonical:   net.Canonical,
+		Reference:   n.Reference,
+		Cell:        c.Cell,
+		Hidden:      joinInts(c.HiddenSizes),
+		Dropout:     dropout.String(),
+		PreLayers:   joinInts(c.PreLayers),
+		History:     c.History,
+		Phase:       c.Phase,
+		Methods:     strings.Join(c.Methods, ","),
+		Solvers:     len(c.Solvers) > 0,
+		Value:       c.Value,
+		ValueWeight: n.ValueWeight,
+	}
+	if net.Canonical {
+		actual.Reference = net.Reference
+	}
+	if actual != *n {
+		log.Println("Warning: network options do not match the loaded network, " +
+			"which is used as it is.")
+	}
+	*n = actual
+}
+
+func parseInts(list string) ([]int, error) {
+	var res []int
+	for _, field := range splitList(list) {
+		num, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, num)
+	}
+	return res, nil
+}
+
+func joinInts(nums []int) string {
+	parts := make([]string, len(nums))
+	for i, num := range nums {
+		parts[i] = strconv.Itoa(num)
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitList(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool {
+		return r == ','
+	})
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/humancube"
+	"github.com/unixpickle/sgd"
+)
+
+// parallelGradienter splits each batch into shards and
+// computes their gradients concurrently, one shard per
+// Gradienter, then sums the results.
+type parallelGradienter struct {
+	Gradienters []sgd.Gradienter
+}
+
+func (p *parallelGradienter) Gradient(s sgd.SampleSet) autofunc.Gradient {
+	shardSize := (s.Len() + len(p.Gradienters) - 1) / len(p.Gradienters)
+	var grads []autofunc.Gradient
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for i, g := range p.Gradienters {
+		start := i * shardSize
+		if start >= s.Len() {
+			break
+		}
+		end := start + shardSize
+		if end > s.Len() {
+			end = s.Len()
+		}
+		wg.Add(1)
+		go func(g sgd.Gradienter, shard sgd.SampleSet) {
+			defer wg.Done()
+			grad := g.Gradient(shard)
+			lock.Lock()
+			grads = append(grads, grad)
+			lock.Unlock()
+		}(g, s.Subset(start, end))
+	}
+	wg.Wait()
+
+	res := grads[0]
+	for _, grad := range grads[1:] {
+		for variable, vec := range grad {
+			if sum, ok := res[variable]; ok {
+				sum.Add(vec)
+			} else {
+				res[variable] = vec
+			}
+		}
+	}
+	return res
+}
+
+// preparedSet is a batch whose samples have already been
+// generated by GetSample.
+type preparedSet []interface{}
+
+func (p preparedSet) Len() int {
+	return len(p)
+}
+
+func (p preparedSet) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+func (p preparedSet) GetSample(idx int) interface{} {
+	return p[idx]
+}
+
+func (p preparedSet) Copy() sgd.SampleSet {
+	return append(preparedSet{}, p...)
+}
+
+func (p preparedSet) Subset(i, j int) sgd.SampleSet {
+	return p[i:j]
+}
+
+// prepareBatch generates every sample of a batch using
+// the given number of goroutines.
+func prepareBatch(s sgd.SampleSet, workers int) preparedSet {
+	res := make(preparedSet, s.Len())
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for j := offset; j < len(res); j += workers {
+				res[j] = s.GetSample(j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return res
+}
+
+// sgdPrefetch is like sgd.SGDMini, but it prepares up to
+// prefetch batches in the background while gradients are
+// being computed.
+func sgdPrefetch(g sgd.Gradienter, samples sgd.SampleSet, stepSize float64,
+	batchSize, workers, prefetch int, sf func(sgd.SampleSet) bool) {
+	batches := make(chan preparedSet, prefetch)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		defer close(batches)
+		for {
+			epoch := samples.Copy()
+			shuffleSamples(epoch)
+			for i := 0; i+batchSize <= epoch.Len(); i += batchSize {
+				batch := prepareBatch(epoch.Subset(i, i+batchSize), workers)
+				select {
+				case batches <- batch:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	for batch := range batches {
+		if !sf(batch) {
+			return
+		}
+		g.Gradient(batch).AddToVars(-stepSize)
+	}
+}
+
+// shuffleSamples shuffles a sample set, using the
+// cache-friendly Shuffle method of StoreSampleSets.
+func shuffleSamples(s sgd.SampleSet) {
+	if storeSet, ok := s.(*humancube.StoreSampleSet); ok {
+		storeSet.Shuffle()
+	} else {
+		sgd.ShuffleSampleSet(s)
+	}
+}
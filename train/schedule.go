@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/sgd"
+)
+
+// These are the supported learning rate schedules.
+const (
+	ConstantSchedule = "constant"
+	StepSchedule     = "step"
+	CosineSchedule   = "cosine"
+)
+
+type ScheduleOptions struct {
+	// Kind is ConstantSchedule, StepSchedule, or
+	// CosineSchedule.
+	Kind string `json:"kind" yaml:"kind"`
+
+	// WarmupBatches is the number of batches over which the
+	// step size ramps up linearly from 0.
+	WarmupBatches int `json:"warmup_batches" yaml:"warmup_batches"`
+
+	// StepBatches and StepFactor configure StepSchedule,
+	// which multiplies the step size by StepFactor every
+	// StepBatches batches.
+	StepBatches int     `json:"step_batches" yaml:"step_batches"`
+	StepFactor  float64 `json:"step_factor" yaml:"step_factor"`
+
+	// CosineBatches and MinFactor configure CosineSchedule,
+	// which anneals the step size down to MinFactor times
+	// its initial value over CosineBatches batches.
+	CosineBatches int     `json:"cosine_batches" yaml:"cosine_batches"`
+	MinFactor     float64 `json:"min_factor" yaml:"min_factor"`
+
+	// PlateauPatience, if non-zero, is the number of
+	// validation evaluations without improvement after which
+	// the step size is multiplied by PlateauFactor.
+	PlateauPatience int     `json:"plateau_patience" yaml:"plateau_patience"`
+	PlateauFactor   float64 `json:"plateau_factor" yaml:"plateau_factor"`
+
+	// EarlyStopPatience, if non-zero, is the number of
+	// validation evaluations without improvement after which
+	// training stops.
+	EarlyStopPatience int `json:"early_stop_patience" yaml:"early_stop_patience"`
+}
+
+// Validate checks that the options are usable.
+func (s *ScheduleOptions) Validate() error {
+	switch s.Kind {
+	case ConstantSchedule:
+	case StepSchedule:
+		if s.StepBatches <= 0 || s.StepFactor <= 0 {
+			return errors.New("step schedule needs positive step batches and factor")
+		}
+	case CosineSchedule:
+		if s.CosineBatches <= 0 || s.MinFactor < 0 {
+			return errors.New("cosine schedule needs positive batches and a non-negative " +
+				"minimum factor")
+		}
+	default:
+		return errors.New("unknown schedule: " + s.Kind)
+	}
+	if s.WarmupBatches < 0 || s.PlateauPatience < 0 || s.EarlyStopPatience < 0 {
+		return errors.New("schedule batch counts must not be negative")
+	}
+	if s.PlateauPatience > 0 && (s.PlateauFactor <= 0 || s.PlateauFactor >= 1) {
+		return errors.New("plateau factor must be between 0 and 1")
+	}
+	return nil
+}
+
+// ScheduleState is the part of a schedule which depends on
+// past validation costs.
+type ScheduleState struct {
+	// PlateauScale is the product of every plateau
+	// reduction so far.
+	PlateauScale float64
+
+	// BadEvals is the number of evaluations since the best
+	// validation cost was reached.
+	BadEvals int
+
+	// PlateauEvals counts evaluations since the best one
+	// or the last plateau reduction.
+	PlateauEvals int
+}
+
+// NewScheduleState creates the state for the start of
+// training.
+func NewScheduleState() *ScheduleState {
+	return &ScheduleState{PlateauScale: 1}
+}
+
+// StepSize computes the step size for a batch.
+func (s *ScheduleOptions) StepSize(base float64, state *ScheduleState, batch int) float64 {
+	res := base * state.PlateauScale
+	switch s.Kind {
+	case StepSchedule:
+		res *= math.Pow(s.StepFactor, float64(batch/s.StepBatches))
+	case CosineSchedule:
+		frac := math.Min(1, float64(batch)/float64(s.CosineBatches))
+		res *= s.MinFactor + (1-s.MinFactor)*(1+math.Cos(math.Pi*frac))/2
+	}
+	if batch < s.WarmupBatches {
+		res *= float64(batch+1) / float64(s.WarmupBatches)
+	}
+	return res
+}
+
+// Update records whether a validation evaluation improved
+// on the best one, reducing the step size on plateaus.
+// It returns true if training should stop early.
+func (s *ScheduleOptions) Update(state *ScheduleState, improved bool) bool {
+	if improved {
+		state.BadEvals = 0
+		state.PlateauEvals = 0
+		return false
+	}
+	state.BadEvals++
+	state.PlateauEvals++
+	if s.PlateauPatience > 0 && state.PlateauEvals >= s.PlateauPatience {
+		state.PlateauScale *= s.PlateauFactor
+		state.PlateauEvals = 0
+	}
+	return s.EarlyStopPatience > 0 && state.BadEvals >= s.EarlyStopPatience
+}
+
+// scaledGradienter scales the update direction of another
+// Gradienter, so that SGD can run with a step size of 1
+// while the real step size changes over time.
+type scaledGradienter struct {
+	Gradienter sgd.Gradienter
+	Scale      float64
+}
+
+func (s *scaledGradienter) Gradient(samples sgd.SampleSet) autofunc.Gradient {
+	grad := s.Gradienter.Gradient(samples)
+	for _, vec := range grad {
+		vec.Scale(s.Scale)
+	}
+	return grad
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math/rand"
+
+	"github.com/unixpickle/humancube"
+	"github.com/unixpickle/sgd"
+)
+
+// storeTrainingSets augments the training samples and
+// streams them into one SampleStore per curriculum stage.
+// Stores which were already written from the same data
+// with the same options are reopened instead of rewritten.
+func storeTrainingSets(config *TrainConfig, net *humancube.Network,
+	training *humancube.SampleSet) ([]sgd.SampleSet, error) {
+	opts := &config.Store
+	numStages := config.Curriculum.numStages()
+	stores := make([]*humancube.SampleStore, numStages)
+	var writers []*humancube.SampleStoreWriter
+	var writerStages []int
+	data, err := ioutil.ReadFile(config.DataFile)
+	if err != nil {
+		return nil, errors.New("hash training data: " + err.Error())
+	}
+	dataHash := sha256.Sum256(data)
+	for stage := range stores {
+		key, err := storeKey(config, net, training, dataHash[:], stage)
+		if err != nil {
+			return nil, errors.New("hash training data: " + err.Error())
+		}
+		dir := stageShardDir(opts.Dir, stage, numStages)
+		store, err := humancube.OpenSampleStore(dir, opts.CacheSize)
+		if err == nil && store.Key == key {
+			log.Println("Reusing training shards in", dir)
+			stores[stage] = store
+			continue
+		}
+		log.Println("Writing training shards to", dir)
+		w, err := humancube.NewSampleStoreWriter(dir, training.MoveMap, opts.ShardSize)
+		if err != nil {
+			return nil, errors.New("write shards: " + err.Error())
+		}
+		w.Key = key
+		writers = append(writers, w)
+		writerStages = append(writerStages, stage)
+	}
+
+	if len(writers) > 0 {
+		weights := config.Weights.SampleWeights()
+		add := func(sample humancube.Sample) error {
+			sample.Weight = weights.Weight(&sample)
+			for i, w := range writers {
+				stageSample := sample
+				if numStages > 1 {
+					minProgress := humancube.CurriculumStages[writerStages[i]].MinProgress
+					var ok bool
+					if stageSample, ok = humancube.SampleFrom(sample, minProgress); !ok {
+						continue
+					}
+				}
+				if err := w.Add(stageSample); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, sample := range training.Samples {
+			if err := add(sample); err != nil {
+				return nil, errors.New("write shards: " + err.Error())
+			}
+		}
+		log.Printf("Augmenting %d training samples...", training.Len())
+		// Augment the samples the same way every time.
+		rand.Seed(config.Seeds.Augment)
+		err := humancube.AugmentEach(training, config.Augment.AugmentParams(), add)
+		if err != nil {
+			return nil, errors.New("write shards: " + err.Error())
+		}
+		for i, w := range writers {
+			stores[writerStages[i]], err = w.Close(opts.CacheSize)
+			if err != nil {
+				return nil, errors.New("write shards: " + err.Error())
+			}
+		}
+	}
+
+	res := make([]sgd.SampleSet, numStages)
+	for i, store := range stores {
+		storeSet := store.SampleSet()
+		storeSet.Encoder = net.Encoder
+		storeSet.Inputs = net.Config.InputConfig
+		storeSet.Outputs = net.Config.OutputConfig
+		res[i] = storeSet
+	}
+	return res, nil
+}
+
+// storeKey identifies the samples which are written to
+// the store for a curriculum stage.
+// It changes whenever the data file, whose hash is given,
+// or any option which affects the training samples
+// changes.
+func storeKey(config *TrainConfig, net *humancube.Network, training *humancube.SampleSet,
+	dataHash []byte, stage int) (string, error) {
+	desc, err := json.Marshal(map[string]interface{}{
+		"data":      hex.EncodeToString(dataHash),
+		"samples":   training.Len(),
+		"moves":     training.MoveMap,
+		"canonical": net.Canonical,
+		"reference": net.Reference,
+		"split":     config.Split,
+		"seeds":     []int64{config.Seeds.Split, config.Seeds.Augment},
+		"augment":   config.Augment,
+		"weights":   config.Weights,
+		"shard":     config.Store.ShardSize,
+		"stage":     stage,
+		"stages":    config.Curriculum.numStages(),
+	})
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(desc)
+	return hex.EncodeToString(hash[:]), nil
+}
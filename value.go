@@ -0,0 +1,204 @@
+package humancube
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/unixpickle/autofunc"
+	"github.com/unixpickle/gocube"
+	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/serializer"
+	"github.com/unixpickle/weakai/neuralnet"
+)
+
+// These are the quantities a value head may predict.
+//
+// ValueSolve predicts the number of moves left in the
+// solve, while ValuePhase predicts the number of moves
+// left until the phase (see DetectPhase) changes.
+const (
+	ValueNone  = ""
+	ValueSolve = "solve"
+	ValuePhase = "phase"
+)
+
+// valueScale is the number of moves represented by a
+// value output of 1.
+const valueScale = 50.0
+
+func init() {
+	var p PolicyValueLayer
+	serializer.RegisterTypedDeserializer(p.SerializerType(), DeserializePolicyValueLayer)
+}
+
+// An OutputConfig describes what a network predicts in
+// addition to the next move.
+type OutputConfig struct {
+	// Value specifies what the value head predicts, or
+	// ValueNone for networks without a value head.
+	Value string
+}
+
+// Validate checks that the configuration is valid.
+func (o *OutputConfig) Validate() error {
+	switch o.Value {
+	case ValueNone, ValueSolve, ValuePhase:
+		return nil
+	}
+	return errors.New("unknown value head: " + o.Value)
+}
+
+// Size returns the number of outputs, given the number of
+// moves in the network's MoveMap.
+func (o *OutputConfig) Size(numMoves int) int {
+	if o.Value == ValueNone {
+		return numMoves
+	}
+	return numMoves + 1
+}
+
+// targets generates the desired outputs for every move of
+// a sample.
+// The states argument includes the state after the final
+// move.
+func (o *OutputConfig) targets(states []gocube.CubieCube, moveIndices []int,
+	numMoves int, weight float64) []linalg.Vector {
+	values := valueTargets(states, o.Value)
+	res := make([]linalg.Vector, len(moveIndices))
+	for i, idx := range moveIndices {
+		res[i] = make(linalg.Vector, o.Size(numMoves))
+		res[i][idx] = weight
+		if values != nil {
+			res[i][numMoves] = values[i]
+		}
+	}
+	return res
+}
+
+// valueTargets computes the scaled value target for each
+// state but the last.
+// Targets which cannot be determined from the sample,
+// such as moves remaining in a sample that does not end
+// solved, are NaN.
+func valueTargets(states []gocube.CubieCube, mode string) []float64 {
+	if mode == ValueNone {
+		return nil
+	}
+	res := make([]float64, len(states)-1)
+	for i := range res {
+		res[i] = math.NaN()
+	}
+	last := len(states) - 1
+	switch mode {
+	case ValueSolve:
+		if states[last].Solved() {
+			for i := range res {
+				res[i] = float64(last-i) / valueScale
+			}
+		}
+	case ValuePhase:
+		nextChange := -1
+		for i := last - 1; i >= 0; i-- {
+			if DetectPhase(&states[i]) != DetectPhase(&states[i+1]) {
+				nextChange = i + 1
+			}
+			if nextChange >= 0 {
+				res[i] = float64(nextChange-i) / valueScale
+			}
+		}
+	}
+	return res
+}
+
+// A PolicyValueLayer applies a log-softmax to the first
+// MoveCount inputs and leaves the rest as they are, so
+// that one dense layer can feed both a policy and a value
+// head.
+type PolicyValueLayer struct {
+	MoveCount int
+}
+
+// DeserializePolicyValueLayer deserializes a
+// PolicyValueLayer.
+func DeserializePolicyValueLayer(d []byte) (*PolicyValueLayer, error) {
+	var res PolicyValueLayer
+	if err := json.Unmarshal(d, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Apply applies the layer to an input.
+func (p *PolicyValueLayer) Apply(in autofunc.Result) autofunc.Result {
+	size := len(in.Output())
+	policy := (&neuralnet.LogSoftmaxLayer{}).Apply(autofunc.Slice(in, 0, p.MoveCount))
+	return autofunc.Concat(policy, autofunc.Slice(in, p.MoveCount, size))
+}
+
+// ApplyR applies the layer to an input.
+func (p *PolicyValueLayer) ApplyR(v autofunc.RVector, in autofunc.RResult) autofunc.RResult {
+	size := len(in.Output())
+	policy := (&neuralnet.LogSoftmaxLayer{}).ApplyR(v, autofunc.SliceR(in, 0, p.MoveCount))
+	return autofunc.ConcatR(policy, autofunc.SliceR(in, p.MoveCount, size))
+}
+
+// Serialize serializes the layer.
+func (p *PolicyValueLayer) Serialize() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// SerializerType returns the unique ID used to serialize
+// a PolicyValueLayer.
+func (p *PolicyValueLayer) SerializerType() string {
+	return "github.com/unixpickle/humancube.PolicyValueLayer"
+}
+
+// PolicyValueCost is a neuralnet.CostFunc for networks
+// with a value head.
+// It adds the dot cost of the policy to the squared error
+// of the value, scaled by ValueWeight and by the sample's
+// weight.
+// Expected values which are NaN are ignored.
+type PolicyValueCost struct {
+	MoveCount   int
+	ValueWeight float64
+}
+
+// Cost computes the cost of an output.
+func (p *PolicyValueCost) Cost(expected linalg.Vector, actual autofunc.Result) autofunc.Result {
+	n := p.MoveCount
+	policyCost := neuralnet.DotCost{}.Cost(expected[:n], autofunc.Slice(actual, 0, n))
+	if p.ValueWeight == 0 || math.IsNaN(expected[n]) {
+		return policyCost
+	}
+	value := autofunc.Slice(actual, n, n+1)
+	valueCost := neuralnet.MeanSquaredCost{}.Cost(expected[n:n+1], value)
+	return autofunc.Add(policyCost, autofunc.Scale(valueCost,
+		p.ValueWeight*sampleWeightOf(expected[:n])))
+}
+
+// CostR computes the cost of an output.
+func (p *PolicyValueCost) CostR(v autofunc.RVector, expected linalg.Vector,
+	actual autofunc.RResult) autofunc.RResult {
+	n := p.MoveCount
+	policyCost := neuralnet.DotCost{}.CostR(v, expected[:n], autofunc.SliceR(actual, 0, n))
+	if p.ValueWeight == 0 || math.IsNaN(expected[n]) {
+		return policyCost
+	}
+	value := autofunc.SliceR(actual, n, n+1)
+	valueCost := neuralnet.MeanSquaredCost{}.CostR(v, expected[n:n+1], value)
+	return autofunc.AddR(policyCost, autofunc.ScaleR(valueCost,
+		p.ValueWeight*sampleWeightOf(expected[:n])))
+}
+
+// sampleWeightOf recovers a sample's weight from the
+// policy part of an expected output, which is a one-hot
+// vector scaled by the weight.
+func sampleWeightOf(policy linalg.Vector) float64 {
+	var res float64
+	for _, x := range policy {
+		res += x
+	}
+	return res
+}
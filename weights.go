@@ -0,0 +1,66 @@
+package humancube
+
+// These are the Origins of augmented samples.
+const (
+	OriginCrossover = "crossover"
+	OriginLastLayer = "last layer"
+	OriginCrossSkip = "cross skip"
+	OriginFirstSkip = "first skip"
+
+	// OriginSearch marks synthetic samples which were
+	// solved by search instead of a human.
+	OriginSearch = "search"
+)
+
+// SampleWeights assigns weights to samples based on where
+// they came from.
+// A sample's weight is the product of every weight which
+// applies to it.
+// All weights should be positive.
+type SampleWeights struct {
+	// Augmented is the weight of samples with an Origin.
+	// A value of 0 is treated as 1.
+	Augmented float64
+
+	// Origins, Methods, and Solvers map names to weights.
+	// Names which are not listed have a weight of 1.
+	Origins map[string]float64
+	Methods map[string]float64
+	Solvers map[string]float64
+}
+
+// Weight computes the weight for a sample.
+func (s *SampleWeights) Weight(sample *Sample) float64 {
+	res := 1.0
+	if sample.Origin != "" {
+		res *= sampleWeight(s.Augmented)
+		if w, ok := s.Origins[sample.Origin]; ok {
+			res *= w
+		}
+	}
+	if w, ok := s.Methods[sample.Method]; ok {
+		res *= w
+	}
+	if w, ok := s.Solvers[sample.Solver]; ok {
+		res *= w
+	}
+	return res
+}
+
+// ApplyWeights sets the Weight of every sample.
+func (s *SampleSet) ApplyWeights(w *SampleWeights) {
+	for i := range s.Samples {
+		s.Samples[i].Weight = w.Weight(&s.Samples[i])
+	}
+}
+
+func (s *Sample) weight() float64 {
+	return sampleWeight(s.Weight)
+}
+
+func sampleWeight(w float64) float64 {
+	if w == 0 {
+		return 1
+	}
+	return w
+}